@@ -1,9 +1,19 @@
 package app
 
 import (
+	"backend/internal/auth"
 	"backend/internal/config"
+	"backend/internal/domain/reminder"
+	"backend/internal/domain/session"
+	"backend/internal/domain/smer"
+	"backend/internal/domain/user"
+	pkgauth "backend/pkg/auth"
 	"backend/pkg/client/postgresql"
+	"backend/pkg/jobs"
 	"backend/pkg/logging"
+	"backend/pkg/mailer"
+	"backend/pkg/outbox"
+	"backend/pkg/utils"
 	"context"
 	"errors"
 	"fmt"
@@ -19,14 +29,39 @@ import (
 )
 
 type App struct {
-	cfg        *config.Config
-	logger     *logging.Logger
-	router     *httprouter.Router
-	httpServer *http.Server
-	pgClient   *pgxpool.Pool
+	cfg         *config.Config
+	logger      *logging.Logger
+	router      *httprouter.Router
+	httpServer  *http.Server
+	pgClient    *pgxpool.Pool
+	jobQueue    *jobs.Queue
+	revocations *session.Revocations
 }
 
 func NewApp(config *config.Config, logger *logging.Logger) (App, error) {
+	logger.Println("jwt keys initializing")
+	keys, err := pkgauth.LoadKeys(pkgauth.KeyConfig{
+		Algorithm:        config.Jwt.Algorithm,
+		KeyId:            config.Jwt.KeyId,
+		Secret:           config.AppConfig.JwtSecret,
+		KeyFile:          config.Jwt.KeyFile,
+		PreviousSecrets:  config.Jwt.PreviousSecrets,
+		PreviousKeyFiles: config.Jwt.PreviousKeyFiles,
+		RotationWindow:   config.Jwt.RotationWindow,
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+	pkgauth.UseKeys(keys)
+
+	if err = utils.UseTrustedProxies(config.Listen.TrustedProxies); err != nil {
+		logger.Fatal(err)
+	}
+	if len(config.Listen.TrustedProxies) == 0 {
+		logger.Warn("TRUSTED_PROXIES is empty, X-Forwarded-For is ignored: " +
+			"behind a reverse proxy every client has its address and shares the rate limits by IP")
+	}
+
 	logger.Println("router initializing")
 
 	pgConfig := postgresql.NewPgConfig(
@@ -38,20 +73,79 @@ func NewApp(config *config.Config, logger *logging.Logger) (App, error) {
 		logger.Fatal(err)
 	}
 
-	router := NewRouter(context.Background(), config, logger, pgClient)
+	jobStorage := jobs.NewJobStorage(context.Background(), pgClient, logger)
+	jobQueue := jobs.NewQueue(context.Background(), jobStorage, logger, jobs.Config{
+		Workers:      config.Jobs.Workers,
+		PollInterval: config.Jobs.PollInterval,
+		Timeout:      config.Jobs.Timeout,
+		MaxAttempts:  config.Jobs.MaxAttempts,
+		MinBackoff:   config.Jobs.MinBackoff,
+		MaxBackoff:   config.Jobs.MaxBackoff,
+	})
+
+	sessionStorage := session.NewSessionStorage(context.Background(), pgClient, logger, config.Sessions.RefreshTTL, config.Sessions.RefreshReuseGrace)
+	revocations := session.NewRevocations(context.Background(), sessionStorage, logger,
+		config.Sessions.RevocationCacheTTL, config.Sessions.RevocationWindow)
+	pkgauth.UseRevocations(revocations)
+
+	router := NewRouter(context.Background(), config, logger, pgClient, jobQueue)
 
 	return App{
-		cfg:      config,
-		logger:   logger,
-		router:   router,
-		pgClient: pgClient,
+		cfg:         config,
+		logger:      logger,
+		router:      router,
+		pgClient:    pgClient,
+		jobQueue:    jobQueue,
+		revocations: revocations,
 	}, nil
 }
 
 func (a *App) Run() {
+	a.startWorkers()
 	a.startHTTP()
 }
 
+func (a *App) startWorkers() {
+	a.logger.Info("start workers")
+
+	ctx := context.Background()
+
+	sender := mailer.SenderConfig{
+		Host:     a.cfg.Mailer.Host,
+		Port:     a.cfg.Mailer.Port,
+		Username: a.cfg.Mailer.Username,
+		Password: a.cfg.Mailer.Password,
+	}
+	mailer.GetMailer(sender, a.logger).HandleJobs(a.jobQueue)
+	go a.jobQueue.Run()
+
+	go a.revocations.Listen(a.pgClient)
+
+	outboxStorage := outbox.NewOutboxStorage(ctx, a.pgClient, a.logger)
+	dispatcher := outbox.NewDispatcher(ctx, outboxStorage, a.logger, outbox.Config{
+		Interval:    a.cfg.Outbox.DispatchInterval,
+		MaxAttempts: a.cfg.Outbox.MaxAttempts,
+		MinBackoff:  a.cfg.Outbox.MinBackoff,
+		MaxBackoff:  a.cfg.Outbox.MaxBackoff,
+	})
+	auth.GetMailerAuth(a.jobQueue, a.logger).HandleSignups(dispatcher, a.cfg)
+	go dispatcher.Run()
+
+	userStorage := user.NewUserStorage(ctx, a.pgClient, a.logger)
+	smerStorage := smer.NewSmerStorage(ctx, a.pgClient, a.logger)
+
+	reminderStorage := reminder.NewReminderStorage(ctx, a.pgClient, a.logger)
+
+	accountWorker := user.NewAccountWorker(ctx, userStorage, smerStorage, reminderStorage, a.logger, a.cfg, a.jobQueue)
+	go accountWorker.Run()
+
+	smerPurger := smer.NewSmerPurger(ctx, smerStorage, a.logger, a.cfg.Smers.TrashRetention, a.cfg.Smers.PurgeInterval)
+	go smerPurger.Run()
+
+	reminderScheduler := reminder.NewReminderScheduler(ctx, reminderStorage, a.logger, a.cfg, a.jobQueue)
+	go reminderScheduler.Run()
+}
+
 func (a *App) startHTTP() {
 	a.logger.Info("start HTTP")
 
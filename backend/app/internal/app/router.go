@@ -4,12 +4,21 @@ import (
 	_ "backend/docs"
 	"backend/internal/auth"
 	"backend/internal/config"
+	"backend/internal/domain/distortion"
 	"backend/internal/domain/files"
+	"backend/internal/domain/reminder"
+	"backend/internal/domain/session"
+	"backend/internal/domain/share"
 	"backend/internal/domain/smer"
+	"backend/internal/domain/tag"
+	"backend/internal/domain/twofactor"
 	"backend/internal/domain/user"
+	"backend/internal/domain/vocabulary"
+	"backend/pkg/jobs"
 	"backend/pkg/logging"
 	"backend/pkg/metric"
 	"backend/pkg/oauth"
+	"backend/pkg/ratelimit"
 	"context"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/julienschmidt/httprouter"
@@ -21,7 +30,7 @@ type Handler interface {
 	Register(router *httprouter.Router)
 }
 
-func NewRouter(ctx context.Context, config *config.Config, logger *logging.Logger, pgClient *pgxpool.Pool) *httprouter.Router {
+func NewRouter(ctx context.Context, config *config.Config, logger *logging.Logger, pgClient *pgxpool.Pool, jobQueue *jobs.Queue) *httprouter.Router {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,13 +50,27 @@ func NewRouter(ctx context.Context, config *config.Config, logger *logging.Logge
 	filesStorage := files.NewFilesStorage(ctx, pgClient, logger)
 
 	userStorage := user.NewUserStorage(ctx, pgClient, logger)
-	userHandler := user.NewUserHandler(ctx, userStorage, logger, filesStorage)
+	userHandler := user.NewUserHandler(ctx, userStorage, logger, filesStorage, config)
 	userHandler.Register(router)
 
-	authHandler := auth.NewAuthHandler(ctx, userStorage, logger, config)
+	sessionStorage := session.NewSessionStorage(ctx, pgClient, logger, config.Sessions.RefreshTTL, config.Sessions.RefreshReuseGrace)
+	sessionHandler := session.NewSessionHandler(ctx, sessionStorage, logger)
+	sessionHandler.Register(router)
+
+	twoFactorStorage := twofactor.NewTwoFactorStorage(ctx, pgClient, logger, config.TwoFactor.Issuer)
+	twoFactorHandler := twofactor.NewTwoFactorHandler(ctx, twoFactorStorage, logger)
+	twoFactorHandler.Register(router)
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.RateLimit.Store == "postgres" {
+		rateLimitStore = ratelimit.NewRateLimitStorage(ctx, pgClient, logger)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStore, logger)
+
+	authHandler := auth.NewAuthHandler(ctx, userStorage, sessionStorage, twoFactorStorage, logger, config, jobQueue, limiter)
 	authHandler.Register(router)
 
-	oauthProvider := oauth.GetOAuthProvider(logger, config, userStorage)
+	oauthProvider := oauth.GetOAuthProvider(logger, config, userStorage, twoFactorStorage, authHandler)
 	oauthProvider.UseVKAuth(router)
 	oauthProvider.UseGoogleAuth(router)
 
@@ -55,5 +78,25 @@ func NewRouter(ctx context.Context, config *config.Config, logger *logging.Logge
 	smerHandler := smer.NewSmerHandler(ctx, smerStorage, logger)
 	smerHandler.Register(router)
 
+	distortionStorage := distortion.NewDistortionStorage(ctx, pgClient, logger)
+	distortionHandler := distortion.NewDistortionHandler(ctx, distortionStorage, logger)
+	distortionHandler.Register(router)
+
+	shareStorage := share.NewShareStorage(ctx, pgClient, logger)
+	shareHandler := share.NewShareHandler(ctx, shareStorage, logger, config, jobQueue)
+	shareHandler.Register(router)
+
+	tagStorage := tag.NewTagStorage(ctx, pgClient, logger)
+	tagHandler := tag.NewTagHandler(ctx, tagStorage, logger)
+	tagHandler.Register(router)
+
+	vocabularyStorage := vocabulary.NewVocabularyStorage(ctx, pgClient, logger)
+	vocabularyHandler := vocabulary.NewVocabularyHandler(ctx, vocabularyStorage, logger, smerStorage)
+	vocabularyHandler.Register(router)
+
+	reminderStorage := reminder.NewReminderStorage(ctx, pgClient, logger)
+	reminderHandler := reminder.NewReminderHandler(ctx, reminderStorage, logger)
+	reminderHandler.Register(router)
+
 	return router
 }
@@ -2,17 +2,23 @@ package auth
 
 import (
 	"backend/internal/config"
+	"backend/internal/domain/session"
+	"backend/internal/domain/twofactor"
 	"backend/internal/domain/user"
 	"backend/pkg/auth"
+	"backend/pkg/jobs"
 	"backend/pkg/logging"
 	"backend/pkg/mailer"
+	"backend/pkg/ratelimit"
 	"backend/pkg/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
@@ -24,10 +30,14 @@ type Credentials struct {
 }
 
 type Handler struct {
-	logger  *logging.Logger
-	storage *user.Storage
-	ctx     context.Context
-	cfg     *config.Config
+	logger    *logging.Logger
+	storage   *user.Storage
+	sessions  *session.Storage
+	twoFactor *twofactor.Storage
+	ctx       context.Context
+	cfg       *config.Config
+	jobQueue  *jobs.Queue
+	limiter   *ratelimit.Limiter
 }
 
 type AuthenticatePayload struct {
@@ -35,35 +45,59 @@ type AuthenticatePayload struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
+// TotpPayload is the second step of a signin.
+type TotpPayload struct {
+	TotpToken string `json:"totpToken"`
+	Code      string `json:"code"`
+}
+
 type ChangePasswordPayload struct {
 	Password string `json:"password"`
 }
 
 const (
 	signinURL         = "/api/auth/signin"
+	signinTotpURL     = "/api/auth/signin/totp"
 	signupURL         = "/api/auth/signup"
 	refreshURL        = "/api/auth/refresh"
 	activateURL       = "/api/auth/activate/:hash"
 	passwordResetURL  = "/api/auth/password-reset"
 	changePasswordURL = "/api/auth/change-password"
+	jwksURL           = "/.well-known/jwks.json"
 )
 
-func NewAuthHandler(ctx context.Context, storage *user.Storage, logger *logging.Logger, cfg *config.Config) *Handler {
+func NewAuthHandler(ctx context.Context, storage *user.Storage, sessions *session.Storage, twoFactor *twofactor.Storage, logger *logging.Logger, cfg *config.Config, jobQueue *jobs.Queue, limiter *ratelimit.Limiter) *Handler {
 	return &Handler{
-		logger:  logger,
-		storage: storage,
-		ctx:     ctx,
-		cfg:     cfg,
+		logger:    logger,
+		storage:   storage,
+		sessions:  sessions,
+		twoFactor: twoFactor,
+		ctx:       ctx,
+		cfg:       cfg,
+		jobQueue:  jobQueue,
+		limiter:   limiter,
 	}
 }
 
 func (h *Handler) Register(router *httprouter.Router) {
-	router.POST(signinURL, h.Signin)
-	router.POST(signupURL, h.Signup)
+	limits := h.cfg.RateLimit
+	lockout := ratelimit.Lockout{
+		Threshold: limits.LockoutThreshold,
+		Window:    limits.LockoutWindow,
+		Base:      limits.LockoutBase,
+		Max:       limits.LockoutMax,
+	}
+	signinRule := ratelimit.Rule{Every: limits.SigninEvery, Burst: limits.SigninBurst, Lockout: lockout}
+	mailRule := ratelimit.Rule{Every: limits.MailEvery, Burst: limits.MailBurst, Lockout: lockout}
+
+	router.POST(signinURL, h.limiter.Limit("signin", signinRule, h.Signin, ratelimit.ByIP, ratelimit.ByEmail))
+	router.POST(signinTotpURL, h.limiter.Limit("signin-totp", signinRule, h.SigninTotp, ratelimit.ByIP, totpUser))
+	router.POST(signupURL, h.limiter.Limit("signup", mailRule, h.Signup, ratelimit.ByIP, ratelimit.ByEmail))
 	router.POST(refreshURL, h.Refresh)
 	router.GET(activateURL, h.Activate)
-	router.POST(passwordResetURL, h.PasswordReset)
+	router.POST(passwordResetURL, h.limiter.Limit("password-reset", mailRule, h.PasswordReset, ratelimit.ByIP, ratelimit.ByEmail))
 	router.POST(changePasswordURL, h.ChangePassword)
+	router.GET(jwksURL, h.JWKS)
 }
 
 func (h *Handler) Signin(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -91,37 +125,95 @@ func (h *Handler) Signin(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		return
 	}
 
-	jwtClaims := auth.AuthJwt{
-		Data: auth.AuthJwtData{
-			Id:    userId,
-			Email: credentials.Email,
-		},
+	// With two-factor authentication on, the tokens are issued by SigninTotp.
+	status, err := h.twoFactor.Status(userId)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if status.Enabled {
+		challenge, err := twofactor.NewChallenge(userId)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		utils.WriteResponse(w, http.StatusOK, challenge)
+		return
 	}
 
-	token, err := auth.Encode(&jwtClaims, 10)
+	h.StartSession(w, r, userId, credentials.Email)
+}
 
-	// TODO email -> token ???
-	refreshJwtClaims := auth.AuthJwt{
-		Data: auth.AuthJwtData{
-			Id:    userId,
-			Email: token,
-		},
+// SigninTotp is the second step of a signin, it takes the token returned by
+// Signin with a TOTP code or a recovery code.
+func (h *Handler) SigninTotp(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var payload TotpPayload
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	refreshToken, err := auth.Encode(&refreshJwtClaims, 10)
+	if err := json.Unmarshal(body, &payload); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userId := twofactor.ChallengeUser(payload.TotpToken)
+	if userId == 0 {
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, auth.ErrInvalidToken.Error())
+		return
+	}
 
-	err = h.storage.UpdateRefreshToken(refreshToken, userId)
+	err = h.twoFactor.Check(userId, payload.Code)
+	if errors.Is(err, twofactor.ErrInvalidCode) || errors.Is(err, twofactor.ErrNotEnabled) {
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
+	userInfo, err := h.storage.GetById(userId)
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
 		return
 	}
-	utils.WriteResponse(w, http.StatusOK, AuthenticatePayload{
-		Token:        token,
-		RefreshToken: refreshToken,
-	})
+
+	h.StartSession(w, r, userId, userInfo.Email)
+}
+
+// totpUser limits the second step per user, whatever the address.
+func totpUser(r *http.Request) string {
+	var payload TotpPayload
+
+	if err := json.Unmarshal(ratelimit.PeekBody(r), &payload); err != nil {
+		return ""
+	}
+	userId := twofactor.ChallengeUser(payload.TotpToken)
+	if userId == 0 {
+		return ""
+	}
+	return "user:" + strconv.FormatUint(uint64(userId), 10)
+}
+
+// StartSession responds with the tokens of a new session, once the user is
+// signed in by a password or an OAuth provider and the second step is done.
+func (h *Handler) StartSession(w http.ResponseWriter, r *http.Request, userId uint16, email string) {
+	sessionId, refreshToken, err := h.sessions.Create(userId, client(r))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.writeTokens(w, userId, email, sessionId, refreshToken)
 }
 
+// Refresh trades a refresh token for a new pair of tokens. The refresh
+// token is used up, presenting it again after the reuse grace revokes the session.
 func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var payload AuthenticatePayload
 
@@ -132,52 +224,60 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request, _ httprouter.P
 		return
 	}
 
-	userId, err := h.storage.IsRefreshTokenActual(payload.Token)
-	if err != nil {
-		h.logger.Error(err)
+	// Older clients send the refresh token as token.
+	refreshToken := payload.RefreshToken
+	if refreshToken == "" {
+		refreshToken = payload.Token
+	}
+
+	userSession, refreshToken, err := h.sessions.Rotate(refreshToken, client(r))
+	if errors.Is(err, session.ErrInvalidRefreshToken) || errors.Is(err, session.ErrRefreshTokenReused) {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
 		return
 	}
-
-	if userId == 0 {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Token is invalid")
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	userInfo, err := h.storage.GetById(userId)
+	userInfo, err := h.storage.GetById(userSession.UserId)
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	jwtClaims := auth.AuthJwt{
-		Data: auth.AuthJwtData{
-			Id:    userId,
-			Email: userInfo.Email,
-		},
-	}
-	payload.Token, err = auth.Encode(&jwtClaims, 10)
+	h.writeTokens(w, userSession.UserId, userInfo.Email, userSession.Id, refreshToken)
+}
 
-	refreshJwtClaims := auth.AuthJwt{
+// writeTokens responds with an access token of the session and its refresh token.
+func (h *Handler) writeTokens(w http.ResponseWriter, userId uint16, email string, sessionId uint64, refreshToken string) {
+	jwtClaims := auth.AuthJwt{
 		Data: auth.AuthJwtData{
-			Id:    userId,
-			Email: payload.Token,
+			Id:        userId,
+			Email:     email,
+			SessionId: sessionId,
 		},
 	}
-	refreshToken, err := auth.Encode(&refreshJwtClaims, 10)
-
-	err = h.storage.UpdateRefreshToken(refreshToken, userId)
 
+	token, err := auth.Encode(&jwtClaims, 10)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+
 	utils.WriteResponse(w, http.StatusOK, AuthenticatePayload{
-		Token:        payload.Token,
+		Token:        token,
 		RefreshToken: refreshToken,
 	})
 }
 
+func client(r *http.Request) session.Client {
+	return session.Client{
+		UserAgent: r.UserAgent(),
+		Ip:        utils.ClientIP(r),
+	}
+}
+
 func (h *Handler) Signup(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var newUser user.User
 
@@ -193,30 +293,13 @@ func (h *Handler) Signup(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		return
 	}
 
-	// TODO Transaction (create & send mail)
-	userId, token, err := h.storage.Create(newUser, false)
+	// The confirmation email goes out through the outbox, see MailerAuth.HandleSignups.
+	userId, _, err := h.storage.Create(newUser, false)
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	cfg := config.GetConfig()
-
-	authMailerClient := GetMailerAuth(cfg, h.logger)
-	activationLink := fmt.Sprintf("%v:%v/api/auth/activate/%v", cfg.Listen.ServerIP, cfg.Listen.Port, token)
-
-	emailConfirmationParams := EmailConfirmationParams{
-		Name:  newUser.Name,
-		Email: newUser.Email,
-		Link:  activationLink,
-	}
-
-	err = authMailerClient.SendMail(newUser.Email, "Email confirmation", EmailConfirmationTemplate, emailConfirmationParams)
-	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Mail error")
-		return
-	}
-
 	utils.WriteResponse(w, http.StatusOK, userId)
 }
 
@@ -259,13 +342,6 @@ func (h *Handler) PasswordReset(w http.ResponseWriter, r *http.Request, _ httpro
 	token, err := h.storage.PasswordReset(userId)
 
 	cfg := config.GetConfig()
-	sender := mailer.SenderConfig{
-		Host:     cfg.Mailer.Host,
-		Port:     cfg.Mailer.Port,
-		Username: cfg.Mailer.Username,
-		Password: cfg.Mailer.Password,
-	}
-	mailClient := mailer.GetMailer(sender, h.logger)
 	activationLink := fmt.Sprintf("%v/change-password?token=%v", cfg.Frontend.ServerIP, token)
 
 	mail := mailer.Mail{
@@ -274,7 +350,7 @@ func (h *Handler) PasswordReset(w http.ResponseWriter, r *http.Request, _ httpro
 		//Text:     result,
 		Text: fmt.Sprintf("Pwd reset link: %v", activationLink),
 	}
-	err = mailClient.Send(mail)
+	_, err = mailer.SendJob.Enqueue(h.jobQueue, mail)
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Mail error")
 		return
@@ -301,3 +377,15 @@ func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request, ps http
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+// JWKS publishes the public keys that verify our tokens, in the plain
+// RFC 7517 format other services expect rather than the API envelope.
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(auth.Keys().JWKS()); err != nil {
+		h.logger.Error(err)
+	}
+}
@@ -2,15 +2,22 @@ package auth
 
 import (
 	"backend/internal/config"
+	"backend/internal/domain/user"
+	"backend/pkg/jobs"
 	"backend/pkg/logging"
 	"backend/pkg/mailer"
+	"backend/pkg/outbox"
 	"bytes"
+	"context"
+	"fmt"
 	"html/template"
 	"os"
+
+	"github.com/jackc/pgx/v4"
 )
 
 type MailerAuth struct {
-	Client *mailer.Mailer
+	Queue  *jobs.Queue
 	Logger *logging.Logger
 }
 
@@ -24,23 +31,31 @@ type EmailConfirmationParams struct {
 	Link  string
 }
 
-func GetMailerAuth(cfg *config.Config, logger *logging.Logger) *MailerAuth {
-	sender := mailer.SenderConfig{
-		Host:     cfg.Mailer.Host,
-		Port:     cfg.Mailer.Port,
-		Username: cfg.Mailer.Username,
-		Password: cfg.Mailer.Password,
-	}
-
-	mailClient := mailer.GetMailer(sender, logger)
-
+// GetMailerAuth renders auth mails, they are sent by the job queue.
+func GetMailerAuth(queue *jobs.Queue, logger *logging.Logger) *MailerAuth {
 	return &MailerAuth{
-		Client: mailClient,
+		Queue:  queue,
 		Logger: logger,
 	}
 }
 
-func (ma *MailerAuth) SendMail(username string, subject string, tmp string, params interface{}) error {
+// HandleSignups sends the confirmation email for every signup in the outbox.
+func (ma *MailerAuth) HandleSignups(dispatcher *outbox.Dispatcher, cfg *config.Config) {
+	user.SignupTopic.Handle(dispatcher, func(_ context.Context, tx pgx.Tx, signup user.Signup) error {
+		activationLink := fmt.Sprintf("%v:%v/api/auth/activate/%v", cfg.Listen.ServerIP, cfg.Listen.Port, signup.Token)
+
+		emailConfirmationParams := EmailConfirmationParams{
+			Name:  signup.Name,
+			Email: signup.Email,
+			Link:  activationLink,
+		}
+
+		return ma.SendMail(tx, signup.Email, "Email confirmation", EmailConfirmationTemplate, emailConfirmationParams)
+	})
+}
+
+// SendMail queues the mail within the transaction.
+func (ma *MailerAuth) SendMail(tx pgx.Tx, username string, subject string, tmp string, params interface{}) error {
 	templateString, err := ma.GetTemplate(tmp, params)
 	if err != nil {
 		return err
@@ -52,7 +67,7 @@ func (ma *MailerAuth) SendMail(username string, subject string, tmp string, para
 		Text:     templateString,
 	}
 
-	err = ma.Client.Send(mail)
+	_, err = mailer.SendJob.EnqueueTx(ma.Queue, tx, mail)
 	if err != nil {
 		return err
 	}
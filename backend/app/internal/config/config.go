@@ -5,6 +5,7 @@ import (
 	"log"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -18,15 +19,45 @@ type Config struct {
 		Port       string `env:"PORT" env-default:"5005"`
 		SocketFile string `env:"SOCKET_FILE" env-default:"app.sock"`
 		ServerIP   string `env:"SERVER_IP" env-default:"https://videot4pe.dev"`
+		// TrustedProxies are allowed to tell the client address with X-Forwarded-For.
+		TrustedProxies []string `env:"TRUSTED_PROXIES" env-separator:"," env-description:"addresses or CIDRs of the reverse proxies, X-Forwarded-For is ignored without them and all clients behind a proxy share its rate limits"`
 	}
 	AppConfig struct {
 		LogLevel  string `env:"LOG_LEVEL" env-default:"trace"`
-		JwtSecret string `env:"JWT_SECRET" env-default:"secret"`
+		JwtSecret string `env:"JWT_SECRET" env-description:"HS512 secret, at least 32 bytes"`
 		AdminUser struct {
 			Email    string `env:"ADMIN_EMAIL" env-default:"admin"`
 			Password string `env:"ADMIN_PWD" env-default:"admin"`
 		}
 	}
+	Jwt struct {
+		Algorithm        string        `env:"JWT_ALGORITHM" env-default:"HS512" env-description:"HS512 (signed with JWT_SECRET), RS256 or EdDSA (signed with JWT_KEY_FILE)"`
+		KeyId            string        `env:"JWT_KEY_ID" env-description:"derived from the key when empty"`
+		KeyFile          string        `env:"JWT_KEY_FILE" env-description:"PEM private key for RS256 or EdDSA"`
+		PreviousSecrets  []string      `env:"JWT_PREVIOUS_SECRETS" env-separator:"," env-description:"kid=secret pairs, a bare secret gets a derived kid"`
+		PreviousKeyFiles []string      `env:"JWT_PREVIOUS_KEY_FILES" env-separator:"," env-description:"kid=path pairs, a bare path gets a derived kid"`
+		RotationWindow   time.Duration `env:"JWT_ROTATION_WINDOW" env-default:"24h" env-description:"how long after the start the previous keys still verify tokens"`
+	}
+	Sessions struct {
+		RefreshTTL         time.Duration `env:"SESSIONS_REFRESH_TTL" env-default:"720h"`
+		RefreshReuseGrace  time.Duration `env:"SESSIONS_REFRESH_REUSE_GRACE" env-default:"30s" env-description:"how long the refresh token just rotated still rotates, for tabs refreshing at once"`
+		RevocationCacheTTL time.Duration `env:"SESSIONS_REVOCATION_CACHE_TTL" env-default:"1m"`
+		RevocationWindow   time.Duration `env:"SESSIONS_REVOCATION_WINDOW" env-default:"1h"`
+	}
+	TwoFactor struct {
+		Issuer string `env:"TWO_FACTOR_ISSUER" env-default:"Smer" env-description:"shown next to the account in authenticator apps"`
+	}
+	RateLimit struct {
+		Store            string        `env:"RATE_LIMIT_STORE" env-default:"memory" env-description:"'memory' or 'postgres' when several replicas run"`
+		SigninEvery      time.Duration `env:"RATE_LIMIT_SIGNIN_EVERY" env-default:"6s"`
+		SigninBurst      int           `env:"RATE_LIMIT_SIGNIN_BURST" env-default:"10"`
+		MailEvery        time.Duration `env:"RATE_LIMIT_MAIL_EVERY" env-default:"1m" env-description:"signup and password reset, they send emails"`
+		MailBurst        int           `env:"RATE_LIMIT_MAIL_BURST" env-default:"3"`
+		LockoutThreshold int           `env:"RATE_LIMIT_LOCKOUT_THRESHOLD" env-default:"5"`
+		LockoutWindow    time.Duration `env:"RATE_LIMIT_LOCKOUT_WINDOW" env-default:"15m"`
+		LockoutBase      time.Duration `env:"RATE_LIMIT_LOCKOUT_BASE" env-default:"1m"`
+		LockoutMax       time.Duration `env:"RATE_LIMIT_LOCKOUT_MAX" env-default:"1h"`
+	}
 	PostgreSQL struct {
 		Username string `env:"PGUSER" env-default:"postgres"`
 		Host     string `env:"PGHOST" env-default:"localhost"`
@@ -53,6 +84,34 @@ type Config struct {
 			CallbackUrl string `env:"VK_OAUTH_CALLBACK_URL" env-default:"http://localhost:5005/api/oauth/vk/callback"`
 		}
 	}
+	Account struct {
+		ExportsDir         string        `env:"ACCOUNT_EXPORTS_DIR" env-default:"exports"`
+		ExportTTL          time.Duration `env:"ACCOUNT_EXPORT_TTL" env-default:"168h"`
+		ErasureGracePeriod time.Duration `env:"ACCOUNT_ERASURE_GRACE_PERIOD" env-default:"720h"`
+		WorkerInterval     time.Duration `env:"ACCOUNT_WORKER_INTERVAL" env-default:"1m"`
+	}
+	Smers struct {
+		TrashRetention time.Duration `env:"SMERS_TRASH_RETENTION" env-default:"720h"`
+		PurgeInterval  time.Duration `env:"SMERS_PURGE_INTERVAL" env-default:"1h"`
+	}
+	Jobs struct {
+		Workers      int           `env:"JOBS_WORKERS" env-default:"2"`
+		PollInterval time.Duration `env:"JOBS_POLL_INTERVAL" env-default:"5s"`
+		Timeout      time.Duration `env:"JOBS_TIMEOUT" env-default:"10m"`
+		MaxAttempts  int           `env:"JOBS_MAX_ATTEMPTS" env-default:"8"`
+		MinBackoff   time.Duration `env:"JOBS_MIN_BACKOFF" env-default:"30s"`
+		MaxBackoff   time.Duration `env:"JOBS_MAX_BACKOFF" env-default:"1h"`
+	}
+	Outbox struct {
+		DispatchInterval time.Duration `env:"OUTBOX_DISPATCH_INTERVAL" env-default:"2s"`
+		MaxAttempts      int           `env:"OUTBOX_MAX_ATTEMPTS" env-default:"10"`
+		MinBackoff       time.Duration `env:"OUTBOX_MIN_BACKOFF" env-default:"10s"`
+		MaxBackoff       time.Duration `env:"OUTBOX_MAX_BACKOFF" env-default:"30m"`
+	}
+	Reminders struct {
+		Interval time.Duration `env:"REMINDERS_INTERVAL" env-default:"1m"`
+		MaxDelay time.Duration `env:"REMINDERS_MAX_DELAY" env-default:"1h"`
+	}
 	Frontend struct {
 		ServerIP string `env:"FRONTEND_SERVER_IP" env-default:"https://videot4pe.dev"`
 		Port     string `env:"FRONTEND_PORT" env-default:"3000"`
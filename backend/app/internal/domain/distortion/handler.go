@@ -0,0 +1,43 @@
+package distortion
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/logging"
+	"backend/pkg/utils"
+	"context"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type Handler struct {
+	logger  *logging.Logger
+	storage *Storage
+	ctx     context.Context
+}
+
+const (
+	distortionsURL = "/api/distortions"
+)
+
+func NewDistortionHandler(ctx context.Context, storage *Storage, logger *logging.Logger) *Handler {
+	return &Handler{
+		logger:  logger,
+		storage: storage,
+		ctx:     ctx,
+	}
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.GET(distortionsURL, auth.RequireAuth(h.GetDistortions))
+}
+
+func (h *Handler) GetDistortions(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	distortions, err := h.storage.All()
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, distortions)
+}
@@ -0,0 +1,8 @@
+package distortion
+
+type Distortion struct {
+	Id          uint16 `json:"id" sql:"id"`
+	Code        string `json:"code" sql:"code"`
+	Name        string `json:"name" sql:"name"`
+	Description string `json:"description" sql:"description"`
+}
@@ -0,0 +1,78 @@
+package distortion
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewDistortionStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme = "public"
+	table  = "distortions"
+)
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+func (s *Storage) All() ([]Distortion, error) {
+	query := s.queryBuilder.Select("id", "code", "name", "description").
+		From(scheme + "." + table).
+		OrderBy("id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	logger.Trace("do query")
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Distortion, 0)
+
+	for rows.Next() {
+		p := Distortion{}
+		if err = rows.Scan(&p.Id, &p.Code, &p.Name, &p.Description); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
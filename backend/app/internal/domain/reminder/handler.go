@@ -0,0 +1,139 @@
+package reminder
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/logging"
+	"backend/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/julienschmidt/httprouter"
+)
+
+type Handler struct {
+	logger  *logging.Logger
+	storage *Storage
+	ctx     context.Context
+}
+
+const (
+	remindersURL = "/api/reminders"
+	reminderURL  = "/api/reminders/:reminderId"
+)
+
+func NewReminderHandler(ctx context.Context, storage *Storage, logger *logging.Logger) *Handler {
+	return &Handler{
+		logger:  logger,
+		storage: storage,
+		ctx:     ctx,
+	}
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.GET(remindersURL, auth.RequireAuth(h.GetReminders))
+	router.POST(remindersURL, auth.RequireAuth(h.CreateReminder))
+	router.PATCH(reminderURL, auth.RequireAuth(h.UpdateReminder))
+	router.DELETE(reminderURL, auth.RequireAuth(h.DeleteReminder))
+}
+
+func (h *Handler) GetReminders(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	reminders, err := h.storage.All(userId)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, reminders)
+}
+
+func (h *Handler) CreateReminder(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	dto, ok := readReminderDto(w, r)
+	if !ok {
+		return
+	}
+
+	reminderId, err := h.storage.Create(userId, dto)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusCreated, reminderId)
+}
+
+func (h *Handler) UpdateReminder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("reminderId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	dto, ok := readReminderDto(w, r)
+	if !ok {
+		return
+	}
+
+	err = h.storage.Update(userId, uint16(id), dto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Reminder not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+func (h *Handler) DeleteReminder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("reminderId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	err = h.storage.Delete(userId, uint16(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Reminder not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+// readReminderDto reads and validates the request body, on failure the response is already written.
+func readReminderDto(w http.ResponseWriter, r *http.Request) (ReminderDto, bool) {
+	var dto ReminderDto
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	if err := json.Unmarshal(body, &dto); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	if err := dto.Validate(); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	return dto, true
+}
@@ -0,0 +1,120 @@
+package reminder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeLayout is the format of the local time of a reminder.
+const timeLayout = "15:04"
+
+type Reminder struct {
+	Id     uint16 `json:"id" sql:"id"`
+	UserId uint16 `json:"-" sql:"user_id"`
+
+	// Weekdays are 0 (Sunday) to 6 (Saturday).
+	Weekdays   []int      `json:"weekdays" sql:"weekdays"`
+	Time       string     `json:"time" sql:"local_time"`
+	Timezone   string     `json:"timezone" sql:"timezone"`
+	Enabled    bool       `json:"enabled" sql:"enabled"`
+	NextRunAt  time.Time  `json:"nextRunAt" sql:"next_run_at"`
+	LastSentAt *time.Time `json:"lastSentAt" sql:"last_sent_at"`
+	CreatedAt  time.Time  `json:"createdAt" sql:"created_at"`
+	UpdatedAt  time.Time  `json:"updatedAt" sql:"updated_at"`
+}
+
+// Due is a reminder whose time has come, with the address to send it to.
+type Due struct {
+	Reminder
+	Email string
+}
+
+type ReminderDto struct {
+	Weekdays []int  `json:"weekdays"`
+	Time     string `json:"time"`
+	Timezone string `json:"timezone"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+func (dto *ReminderDto) Validate() error {
+	if len(dto.Weekdays) == 0 {
+		return errors.New("no weekdays selected")
+	}
+
+	seen := make(map[int]bool, len(dto.Weekdays))
+	weekdays := make([]int, 0, len(dto.Weekdays))
+	for _, day := range dto.Weekdays {
+		if day < int(time.Sunday) || day > int(time.Saturday) {
+			return fmt.Errorf("invalid weekday: %d, expected 0 (Sunday) to 6", day)
+		}
+		if !seen[day] {
+			seen[day] = true
+			weekdays = append(weekdays, day)
+		}
+	}
+	sort.Ints(weekdays)
+	dto.Weekdays = weekdays
+
+	dto.Time = strings.TrimSpace(dto.Time)
+	if _, err := time.Parse(timeLayout, dto.Time); err != nil {
+		return fmt.Errorf("invalid time: '%v', expected HH:MM", dto.Time)
+	}
+
+	// Local is the server's zone, not the user's.
+	if dto.Timezone == "" || dto.Timezone == "Local" {
+		return errors.New("timezone is required")
+	}
+	if _, err := time.LoadLocation(dto.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone: '%v'", dto.Timezone)
+	}
+
+	if dto.Enabled == nil {
+		enabled := true
+		dto.Enabled = &enabled
+	}
+
+	return nil
+}
+
+// nextRun returns the first moment after the given one when the reminder is due.
+// A time skipped by a DST change is moved forward by time.Date.
+func nextRun(weekdays []int, localTime string, timezone string, after time.Time) (time.Time, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	clock, err := time.Parse(timeLayout, localTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	days := make(map[time.Weekday]bool, len(weekdays))
+	for _, day := range weekdays {
+		days[time.Weekday(day)] = true
+	}
+
+	local := after.In(location)
+	for i := 0; i <= 7; i++ {
+		run := time.Date(local.Year(), local.Month(), local.Day()+i, clock.Hour(), clock.Minute(), 0, 0, location)
+		if days[run.Weekday()] && run.After(after) {
+			return run.UTC(), nil
+		}
+	}
+
+	return time.Time{}, errors.New("reminder has no weekdays")
+}
+
+// localDay returns the bounds of the day that contains the moment in the timezone.
+func localDay(at time.Time, timezone string) (time.Time, time.Time, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	local := at.In(location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	return start, start.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,89 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip(err)
+	}
+
+	everyDay := []int{0, 1, 2, 3, 4, 5, 6}
+
+	tests := []struct {
+		name     string
+		weekdays []int
+		time     string
+		timezone string
+		after    time.Time
+		want     time.Time
+		err      bool
+	}{
+		{
+			"later today", everyDay, "21:00", "Europe/Berlin",
+			time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 15, 20, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"tomorrow", everyDay, "09:00", "Europe/Berlin",
+			time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 16, 8, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"exactly at the run", everyDay, "09:00", "Europe/Berlin",
+			time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 16, 8, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"next weekday", []int{1}, "09:00", "Europe/Berlin",
+			time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 19, 8, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"same weekday next week", []int{4}, "09:00", "Europe/Berlin",
+			time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 22, 8, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"across spring forward", everyDay, "09:00", "Europe/Berlin",
+			time.Date(2026, 3, 28, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 29, 7, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"skipped by spring forward", everyDay, "02:30", "Europe/Berlin",
+			time.Date(2026, 3, 28, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC), false,
+		},
+		{
+			"across fall back", everyDay, "09:00", "Europe/Berlin",
+			time.Date(2026, 10, 24, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 25, 8, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"repeated by fall back", everyDay, "02:30", "Europe/Berlin",
+			time.Date(2026, 10, 24, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC), false,
+		},
+		{
+			"utc", everyDay, "00:00", "UTC",
+			time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC),
+			time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), false,
+		},
+		{"no weekdays", nil, "09:00", "Europe/Berlin", time.Now(), time.Time{}, true},
+		{"invalid timezone", everyDay, "09:00", "Mars/Olympus", time.Now(), time.Time{}, true},
+		{"invalid time", everyDay, "9 am", "Europe/Berlin", time.Now(), time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := nextRun(test.weekdays, test.time, test.timezone, test.after)
+		if (err != nil) != test.err {
+			t.Errorf("%v: nextRun error = %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%v: nextRun = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
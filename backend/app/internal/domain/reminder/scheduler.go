@@ -0,0 +1,130 @@
+package reminder
+
+import (
+	"backend/internal/config"
+	"backend/pkg/jobs"
+	"backend/pkg/logging"
+	"backend/pkg/mailer"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// batchSize is how many due reminders are read at once.
+const batchSize = 100
+
+// Scheduler sends reminder emails at the users' local time.
+// Several replicas may run it at once, Storage.Claim keeps a reminder
+// from being sent twice. Emails are delivered by the job queue.
+type Scheduler struct {
+	logger   *logging.Logger
+	storage  *Storage
+	cfg      *config.Config
+	jobQueue *jobs.Queue
+	ctx      context.Context
+}
+
+func NewReminderScheduler(ctx context.Context, storage *Storage, logger *logging.Logger, cfg *config.Config, jobQueue *jobs.Queue) *Scheduler {
+	return &Scheduler{
+		logger:   logger,
+		storage:  storage,
+		cfg:      cfg,
+		jobQueue: jobQueue,
+		ctx:      ctx,
+	}
+}
+
+// Run blocks until the context is done.
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(s.cfg.Reminders.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.processDue()
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) processDue() {
+	for s.ctx.Err() == nil {
+		due, err := s.storage.Due(batchSize)
+		if err != nil {
+			return
+		}
+
+		claimed := 0
+		for _, reminder := range due {
+			if s.process(reminder) {
+				claimed++
+			}
+		}
+
+		// A short batch is the last one, a batch without claims means
+		// the rest is handled by another replica or can't be scheduled.
+		if len(due) < batchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+// process claims the reminder and sends it unless it's late or the user
+// already wrote an entry that day. A reminder that can't be scheduled is
+// disabled. Returns whether the reminder was claimed or disabled.
+func (s *Scheduler) process(reminder Due) bool {
+	now := time.Now()
+
+	next, err := nextRun(reminder.Weekdays, reminder.Time, reminder.Timezone, now)
+	if err != nil {
+		s.logger.Errorf("reminder %d can't be scheduled and is disabled: %v", reminder.Id, err)
+		return s.storage.Disable(reminder.Reminder) == nil
+	}
+
+	var send func(tx pgx.Tx) error
+	if s.due(reminder, now) {
+		send = func(tx pgx.Tx) error {
+			return s.send(tx, reminder)
+		}
+	}
+
+	claimed, err := s.storage.Claim(reminder.Reminder, next, send)
+	if err != nil {
+		s.logger.Errorf("reminder %d: %v", reminder.Id, err)
+	}
+	return claimed
+}
+
+// due tells whether the reminder should be sent: it's not late and the user
+// didn't write an entry that day yet.
+func (s *Scheduler) due(reminder Due, now time.Time) bool {
+	// Missed while no scheduler was running, a reminder hours late is noise.
+	if now.Sub(reminder.NextRunAt) > s.cfg.Reminders.MaxDelay {
+		return false
+	}
+
+	from, to, err := localDay(reminder.NextRunAt, reminder.Timezone)
+	if err != nil {
+		s.logger.Error(err)
+		return false
+	}
+	written, err := s.storage.HasEntry(reminder.UserId, from, to)
+	return err == nil && !written
+}
+
+func (s *Scheduler) send(tx pgx.Tx, reminder Due) error {
+	diaryLink := fmt.Sprintf("%v/smers", s.cfg.Frontend.ServerIP)
+
+	mail := mailer.Mail{
+		Username: reminder.Email,
+		Subject:  "SMER diary reminder",
+		Text:     fmt.Sprintf("Take a few minutes to write down today's situations, thoughts and emotions: %v", diaryLink),
+	}
+	_, err := mailer.SendJob.EnqueueTx(s.jobQueue, tx, mail)
+	return err
+}
@@ -0,0 +1,309 @@
+package reminder
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewReminderStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme     = "public"
+	table      = "reminders"
+	usersTable = "users"
+	smersTable = "smers"
+)
+
+var reminderColumns = []string{
+	table + ".id", table + ".user_id", table + ".weekdays", "to_char(" + table + ".local_time, 'HH24:MI')",
+	table + ".timezone", table + ".enabled", table + ".next_run_at", table + ".last_sent_at",
+	table + ".created_at", table + ".updated_at",
+}
+
+func scanReminder(row pgx.Row, reminder *Reminder, extra ...interface{}) error {
+	return row.Scan(append([]interface{}{
+		&reminder.Id, &reminder.UserId, &reminder.Weekdays, &reminder.Time,
+		&reminder.Timezone, &reminder.Enabled, &reminder.NextRunAt, &reminder.LastSentAt,
+		&reminder.CreatedAt, &reminder.UpdatedAt,
+	}, extra...)...)
+}
+
+// localTime passes HH:MM to a TIME column, pgx can't encode a string as TIME.
+func localTime(value string) sq.Sqlizer {
+	return sq.Expr("?::text::time", value)
+}
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+func (s *Storage) All(userId uint16) ([]Reminder, error) {
+	query := s.queryBuilder.Select(reminderColumns...).
+		From(scheme + "." + table).
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Reminder, 0)
+
+	for rows.Next() {
+		r := Reminder{}
+		if err = scanReminder(rows, &r); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, r)
+	}
+
+	return list, nil
+}
+
+func (s *Storage) Create(userId uint16, dto ReminderDto) (uint16, error) {
+	lastInsertId := uint16(0)
+
+	next, err := nextRun(dto.Weekdays, dto.Time, dto.Timezone, time.Now())
+	if err != nil {
+		return lastInsertId, err
+	}
+
+	query := s.queryBuilder.Insert(scheme+"."+table).
+		Columns("user_id", "weekdays", "local_time", "timezone", "enabled", "next_run_at").
+		Values(userId, dto.Weekdays, localTime(dto.Time), dto.Timezone, *dto.Enabled, next).
+		Suffix("RETURNING id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	logger.Trace("Creating reminder")
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId); err != nil {
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	return lastInsertId, nil
+}
+
+// Update reschedules the reminder. Returns pgx.ErrNoRows when the user has no such reminder.
+func (s *Storage) Update(userId uint16, id uint16, dto ReminderDto) error {
+	next, err := nextRun(dto.Weekdays, dto.Time, dto.Timezone, time.Now())
+	if err != nil {
+		return err
+	}
+
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("weekdays", dto.Weekdays).
+		Set("local_time", localTime(dto.Time)).
+		Set("timezone", dto.Timezone).
+		Set("enabled", *dto.Enabled).
+		Set("next_run_at", next).
+		Where(sq.Eq{"id": id, "user_id": userId})
+
+	return s.exec(query, "Updating reminder")
+}
+
+// Delete returns pgx.ErrNoRows when the user has no such reminder.
+func (s *Storage) Delete(userId uint16, id uint16) error {
+	query := s.queryBuilder.Delete(scheme + "." + table).
+		Where(sq.Eq{"id": id, "user_id": userId})
+
+	return s.exec(query, "Deleting reminder")
+}
+
+// Due returns enabled reminders whose time has come, the longest waiting first.
+func (s *Storage) Due(limit uint64) ([]Due, error) {
+	query := s.queryBuilder.Select(reminderColumns...).
+		Column(usersTable + ".email").
+		From(scheme + "." + table).
+		Join(scheme + "." + usersTable + " ON " + usersTable + ".id = " + table + ".user_id").
+		Where(sq.Eq{table + ".enabled": true}).
+		Where(table + ".next_run_at <= NOW()").
+		OrderBy(table + ".next_run_at").
+		Limit(limit)
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Due, 0)
+
+	for rows.Next() {
+		d := Due{}
+		if err = scanReminder(rows, &d.Reminder, &d.Email); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, d)
+	}
+
+	return list, nil
+}
+
+// Claim moves the reminder to its next run. It only succeeds while the reminder
+// is still scheduled for the run that was read, so of several replicas
+// exactly one gets to send it. A non-nil send is called in the same
+// transaction and the reminder is marked as sent, so a claimed reminder
+// is never lost between the claim and the mail.
+func (s *Storage) Claim(reminder Reminder, next time.Time, send func(tx pgx.Tx) error) (bool, error) {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("next_run_at", next).
+		Where(sq.Eq{"id": reminder.Id, "next_run_at": reminder.NextRunAt, "enabled": true})
+	if send != nil {
+		query = query.Set("last_sent_at", sq.Expr("NOW()"))
+	}
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return false, err
+	}
+
+	claimed := false
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(s.ctx, sql, args...)
+		if err != nil {
+			err = db.ErrDoQuery(err)
+			logger.Error(err)
+			return err
+		}
+		if tag.RowsAffected() == 0 || send == nil {
+			claimed = tag.RowsAffected() == 1
+			return nil
+		}
+
+		if err = send(tx); err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return claimed, nil
+}
+
+// Disable turns off a reminder that can't be scheduled, so it doesn't stay
+// at the head of the due ones. Like Claim, it only applies to the run that was read.
+func (s *Storage) Disable(reminder Reminder) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("enabled", false).
+		Where(sq.Eq{"id": reminder.Id, "next_run_at": reminder.NextRunAt})
+
+	return s.exec(query, "Disabling reminder")
+}
+
+// HasEntry tells whether the user wrote a smer between from and to.
+func (s *Storage) HasEntry(userId uint16, from time.Time, to time.Time) (bool, error) {
+	var exists bool
+
+	entries := sq.Select("1").
+		From(scheme + "." + smersTable).
+		Where(sq.Eq{"user_id": userId, "deleted_at": nil}).
+		Where(sq.GtOrEq{"created_at": from}).
+		Where(sq.Lt{"created_at": to})
+
+	query := s.queryBuilder.Select().Column(sq.Expr("EXISTS (?)", entries))
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, smersTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return false, err
+	}
+
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&exists); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (s *Storage) exec(query sq.Sqlizer, message string) error {
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace(message)
+	tag, err := s.client.Exec(s.ctx, sql, args...)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
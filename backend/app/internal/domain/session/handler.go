@@ -0,0 +1,104 @@
+package session
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/logging"
+	"backend/pkg/utils"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/julienschmidt/httprouter"
+)
+
+type Handler struct {
+	logger  *logging.Logger
+	storage *Storage
+	ctx     context.Context
+}
+
+const (
+	sessionsURL  = "/api/auth/sessions"
+	sessionURL   = "/api/auth/sessions/:sessionId"
+	logoutURL    = "/api/auth/logout"
+	logoutAllURL = "/api/auth/logout-all"
+)
+
+func NewSessionHandler(ctx context.Context, storage *Storage, logger *logging.Logger) *Handler {
+	return &Handler{
+		logger:  logger,
+		storage: storage,
+		ctx:     ctx,
+	}
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.GET(sessionsURL, auth.RequireAuth(h.GetSessions))
+	router.DELETE(sessionURL, auth.RequireAuth(h.RevokeSession))
+	router.POST(logoutURL, auth.RequireAuth(h.Logout))
+	router.POST(logoutAllURL, auth.RequireAuth(h.LogoutAll))
+}
+
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+	sessionId, _ := r.Context().Value("sessionId").(uint64)
+
+	sessions, err := h.storage.All(userId)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == sessionId
+	}
+	utils.WriteResponse(w, http.StatusOK, sessions)
+}
+
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("sessionId"), 16, 64)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	err = h.storage.Revoke(userId, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+// Logout ends the session of the request. Tokens issued before sessions
+// existed cannot be revoked one by one, they simply expire.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+	sessionId, _ := r.Context().Value("sessionId").(uint64)
+
+	if sessionId != 0 {
+		err := h.storage.Revoke(userId, sessionId)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	utils.WriteResponse(w, http.StatusOK, sessionId)
+}
+
+// LogoutAll ends every session of the user and revokes all of the issued access tokens.
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	if err := h.storage.RevokeAll(userId); err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, userId)
+}
@@ -0,0 +1,40 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, the session is revoked")
+)
+
+// Session is a login on one device.
+type Session struct {
+	Id         uint64    `json:"id" sql:"id"`
+	UserId     uint16    `json:"-" sql:"user_id"`
+	UserAgent  string    `json:"userAgent" sql:"user_agent"`
+	Ip         string    `json:"ip" sql:"ip"`
+	ExpiresAt  time.Time `json:"expiresAt" sql:"expires_at"`
+	CreatedAt  time.Time `json:"createdAt" sql:"created_at"`
+	LastUsedAt time.Time `json:"lastUsedAt" sql:"last_used_at"`
+
+	// Current marks the session of the request.
+	Current bool `json:"current"`
+}
+
+// Client describes the device a session is used from.
+type Client struct {
+	UserAgent string
+	Ip        string
+}
+
+// hashToken is what is stored instead of the refresh token. Tokens are
+// long and random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"strconv"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	usersTable = "users"
+
+	// revocationsChannel is notified with the user id whenever tokens of the user are revoked.
+	revocationsChannel = "auth_revocations"
+)
+
+// RevokeAll ends every session of the user and invalidates the access
+// tokens issued so far, including the ones without a session. The moment
+// is kept in whole seconds like the iat of the tokens.
+func (s *Storage) RevokeAll(userId uint16) error {
+	queries := []struct {
+		table string
+		query sq.Sqlizer
+	}{
+		{usersTable, s.queryBuilder.Update(scheme+"."+usersTable).
+			Set("tokens_revoked_at", sq.Expr("date_trunc('second', NOW())")).
+			Where(sq.Eq{"id": userId})},
+		{table, s.queryBuilder.Update(scheme+"."+table).
+			Set("revoked_at", sq.Expr("NOW()")).
+			Where(sq.Eq{table + ".user_id": userId}).
+			Where(active)},
+	}
+
+	return s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		for _, q := range queries {
+			sql, args, err := q.query.ToSql()
+			logger := s.queryLogger(sql, q.table, args)
+			if err != nil {
+				err = db.ErrCreateQuery(err)
+				logger.Error(err)
+				return err
+			}
+
+			logger.Trace("Revoking all sessions")
+			if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+				err = db.ErrDoQuery(err)
+				logger.Error(err)
+				return err
+			}
+		}
+
+		return s.notify(tx, userId)
+	})
+}
+
+// revocations returns the moment before which the user's tokens are revoked
+// (nil when never) and the sessions revoked after since.
+func (s *Storage) revocations(userId uint16, since time.Time) (*time.Time, []uint64, error) {
+	var revokedAt *time.Time
+	sessions := make([]uint64, 0)
+
+	query := s.queryBuilder.Select(
+		"u.tokens_revoked_at",
+		"COALESCE(array_agg(s.id) FILTER (WHERE s.id IS NOT NULL), '{}')",
+	).
+		From(scheme+"."+usersTable+" u").
+		LeftJoin(scheme+"."+table+" s ON s.user_id = u.id AND s.revoked_at > ?", since).
+		Where(sq.Eq{"u.id": userId}).
+		GroupBy("u.id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, nil, err
+	}
+
+	err = s.client.QueryRow(s.ctx, sql, args...).Scan(&revokedAt, &sessions)
+	if err == pgx.ErrNoRows {
+		// The user is gone, so are the sessions.
+		now := time.Now()
+		return &now, sessions, nil
+	}
+	if err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, nil, err
+	}
+
+	return revokedAt, sessions, nil
+}
+
+// notify tells every instance to drop its cached revocations of the user,
+// it is delivered once the transaction commits.
+func (s *Storage) notify(tx pgx.Tx, userId uint16) error {
+	sql := "SELECT pg_notify($1, $2)"
+	args := []interface{}{revocationsChannel, strconv.FormatUint(uint64(userId), 10)}
+	logger := s.queryLogger(sql, table, args)
+
+	if _, err := tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package session
+
+import (
+	"backend/pkg/logging"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Revocations tells RequireAuth whether an access token was revoked by a
+// logout. What is known of a user is cached in process, so most requests do
+// not hit the database. Revoking notifies every instance through Postgres,
+// which drops the cached user, the cache ttl is a fallback for lost
+// notifications.
+type Revocations struct {
+	logger  *logging.Logger
+	storage *Storage
+	ttl     time.Duration
+	window  time.Duration
+	ctx     context.Context
+
+	mu         sync.Mutex
+	users      map[uint16]*revoked
+	generation uint64
+	prunedAt   time.Time
+}
+
+type revoked struct {
+	before   *time.Time
+	sessions map[uint64]struct{}
+	loadedAt time.Time
+}
+
+// NewRevocations caches revocations for ttl. Sessions revoked longer than
+// window ago are not remembered: their access tokens have expired by then.
+func NewRevocations(ctx context.Context, storage *Storage, logger *logging.Logger, ttl time.Duration, window time.Duration) *Revocations {
+	return &Revocations{
+		logger:  logger,
+		storage: storage,
+		ttl:     ttl,
+		window:  window,
+		ctx:     ctx,
+		users:   make(map[uint16]*revoked),
+	}
+}
+
+// Revoked implements auth.RevocationChecker.
+func (r *Revocations) Revoked(userId uint16, sessionId uint64, issuedAt time.Time) (bool, error) {
+	user, err := r.user(userId)
+	if err != nil {
+		return false, err
+	}
+
+	// iat has no fraction of a second, so tokens issued within the second
+	// of the revocation are the ones issued right after it.
+	if user.before != nil && issuedAt.Before(user.before.Truncate(time.Second)) {
+		return true, nil
+	}
+	if sessionId != 0 {
+		if _, ok := user.sessions[sessionId]; ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Forget drops what is cached of the user, the next check loads it again.
+func (r *Revocations) Forget(userId uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, userId)
+	r.generation++
+}
+
+func (r *Revocations) forgetAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users = make(map[uint16]*revoked)
+	r.generation++
+}
+
+func (r *Revocations) user(userId uint16) (*revoked, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	user, ok := r.users[userId]
+	generation := r.generation
+	r.mu.Unlock()
+
+	if ok && now.Sub(user.loadedAt) < r.ttl {
+		return user, nil
+	}
+
+	before, sessions, err := r.storage.revocations(userId, now.Add(-r.window))
+	if err != nil {
+		return nil, err
+	}
+
+	user = &revoked{
+		before:   before,
+		sessions: make(map[uint64]struct{}, len(sessions)),
+		loadedAt: now,
+	}
+	for _, id := range sessions {
+		user.sessions[id] = struct{}{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Something was revoked while loading, the result may miss it.
+	if generation == r.generation {
+		r.users[userId] = user
+	}
+	if now.Sub(r.prunedAt) >= r.ttl {
+		for id, cached := range r.users {
+			if now.Sub(cached.loadedAt) >= r.ttl {
+				delete(r.users, id)
+			}
+		}
+		r.prunedAt = now
+	}
+
+	return user, nil
+}
+
+// Listen forgets the users named by revocation notifications.
+// It reconnects after a failure and blocks until the context is done.
+func (r *Revocations) Listen(pool *pgxpool.Pool) {
+	for {
+		if err := r.listen(pool); err != nil && r.ctx.Err() == nil {
+			r.logger.Errorf("revocations listener: %v", err)
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(time.Second * 5):
+		}
+	}
+}
+
+func (r *Revocations) listen(pool *pgxpool.Pool) error {
+	// A connection of its own, a listening one can not go back to the pool.
+	conn, err := pgx.ConnectConfig(r.ctx, pool.Config().ConnConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(r.ctx, "LISTEN "+revocationsChannel); err != nil {
+		return err
+	}
+	// Notifications sent while not listening are lost.
+	r.forgetAll()
+
+	for {
+		notification, err := conn.WaitForNotification(r.ctx)
+		if err != nil {
+			return err
+		}
+
+		userId, err := strconv.ParseUint(notification.Payload, 10, 16)
+		if err != nil {
+			r.logger.Warnf("revocations listener: bad payload %q", notification.Payload)
+			continue
+		}
+		r.Forget(uint16(userId))
+	}
+}
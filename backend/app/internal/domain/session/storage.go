@@ -0,0 +1,323 @@
+package session
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/dchest/uniuri"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+	refreshTTL   time.Duration
+	// reuseGrace is how long a used refresh token still rotates, so tabs
+	// refreshing at the same time do not look like a stolen token.
+	reuseGrace time.Duration
+}
+
+func NewSessionStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger, refreshTTL, reuseGrace time.Duration) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+		refreshTTL:   refreshTTL,
+		reuseGrace:   reuseGrace,
+	}
+}
+
+const (
+	scheme       = "public"
+	table        = "sessions"
+	tokensTable  = "refresh_tokens"
+	refreshBytes = 64
+)
+
+// active limits sessions to the ones that can still be refreshed.
+var active = sq.And{sq.Eq{table + ".revoked_at": nil}, sq.Expr(table + ".expires_at > NOW()")}
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// Create starts a session and returns its id with the first refresh token.
+func (s *Storage) Create(userId uint16, client Client) (uint64, string, error) {
+	var sessionId uint64
+	var token string
+
+	expiresAt := time.Now().Add(s.refreshTTL)
+
+	query := s.queryBuilder.Insert(scheme+"."+table).
+		Columns("user_id", "user_agent", "ip", "expires_at").
+		Values(userId, client.UserAgent, client.Ip, expiresAt).
+		Suffix("RETURNING id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return 0, "", err
+	}
+
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		logger.Trace("Creating session")
+		if err := tx.QueryRow(s.ctx, sql, args...).Scan(&sessionId); err != nil {
+			logger.Error(err)
+			return err
+		}
+
+		var err error
+		token, err = s.issue(tx, sessionId, expiresAt)
+		return err
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	return sessionId, token, nil
+}
+
+// Rotate uses up the refresh token and returns its session with the next token.
+// A token used within the reuse grace still rotates, another tab may have
+// refreshed a moment before. Used earlier, it revokes the session: someone
+// else holds a copy of the token.
+func (s *Storage) Rotate(token string, client Client) (*Session, string, error) {
+	var session Session
+	var next string
+	var reused bool
+
+	query := s.queryBuilder.Select(
+		tokensTable+".id", tokensTable+".used_at", tokensTable+".expires_at",
+		table+".id", table+".user_id", table+".revoked_at",
+	).
+		From(scheme + "." + tokensTable).
+		Join(scheme + "." + table + " ON " + table + ".id = " + tokensTable + ".session_id").
+		Where(sq.Eq{tokensTable + ".token_hash": hashToken(token)}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, tokensTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, "", err
+	}
+
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		var tokenId uint64
+		var usedAt, revokedAt *time.Time
+		var tokenExpiresAt time.Time
+
+		err := tx.QueryRow(s.ctx, sql, args...).Scan(
+			&tokenId, &usedAt, &tokenExpiresAt, &session.Id, &session.UserId, &revokedAt,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return err
+		}
+
+		if revokedAt != nil || !tokenExpiresAt.After(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+		if usedAt != nil && time.Since(*usedAt) > s.reuseGrace {
+			// Committed, unlike the returned errors.
+			reused = true
+			if err := s.revoke(tx, session.Id); err != nil {
+				return err
+			}
+			return s.notify(tx, session.UserId)
+		}
+
+		expiresAt := time.Now().Add(s.refreshTTL)
+		queries := []struct {
+			table string
+			query sq.Sqlizer
+		}{
+			// A token rotated again within the grace keeps its first use,
+			// the grace does not restart.
+			{tokensTable, s.queryBuilder.Update(scheme+"."+tokensTable).
+				Set("used_at", sq.Expr("COALESCE(used_at, NOW())")).
+				Where(sq.Eq{"id": tokenId})},
+			{tokensTable, s.queryBuilder.Delete(scheme + "." + tokensTable).
+				Where(sq.Eq{"session_id": session.Id}).
+				Where("expires_at <= NOW()")},
+			{table, s.queryBuilder.Update(scheme+"."+table).
+				Set("user_agent", client.UserAgent).
+				Set("ip", client.Ip).
+				Set("expires_at", expiresAt).
+				Set("last_used_at", sq.Expr("NOW()")).
+				Where(sq.Eq{"id": session.Id})},
+		}
+
+		for _, q := range queries {
+			sql, args, err := q.query.ToSql()
+			logger := s.queryLogger(sql, q.table, args)
+			if err != nil {
+				err = db.ErrCreateQuery(err)
+				logger.Error(err)
+				return err
+			}
+
+			if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+				err = db.ErrDoQuery(err)
+				logger.Error(err)
+				return err
+			}
+		}
+
+		next, err = s.issue(tx, session.Id, expiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		logger.Warnf("refresh token reused, session %d revoked", session.Id)
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	return &session, next, nil
+}
+
+// issue stores a new refresh token of the session and returns it.
+func (s *Storage) issue(tx pgx.Tx, sessionId uint64, expiresAt time.Time) (string, error) {
+	token := uniuri.NewLen(refreshBytes)
+
+	query := s.queryBuilder.Insert(scheme+"."+tokensTable).
+		Columns("session_id", "token_hash", "expires_at").
+		Values(sessionId, hashToken(token), expiresAt)
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, tokensTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return "", err
+	}
+
+	logger.Trace("Issuing refresh token")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return "", err
+	}
+
+	return token, nil
+}
+
+// All returns the user's active sessions, the last used first.
+func (s *Storage) All(userId uint16) ([]Session, error) {
+	query := s.queryBuilder.Select("id", "user_id", "user_agent", "ip", "expires_at", "created_at", "last_used_at").
+		From(scheme + "." + table).
+		Where(sq.Eq{"user_id": userId}).
+		Where(active).
+		OrderBy("last_used_at DESC")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Session, 0)
+
+	for rows.Next() {
+		session := Session{}
+		if err = rows.Scan(
+			&session.Id, &session.UserId, &session.UserAgent, &session.Ip,
+			&session.ExpiresAt, &session.CreatedAt, &session.LastUsedAt,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, session)
+	}
+
+	return list, nil
+}
+
+// Revoke ends the user's session, its refresh tokens stop working.
+// Returns pgx.ErrNoRows when the user has no such active session.
+func (s *Storage) Revoke(userId uint16, id uint64) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("revoked_at", sq.Expr("NOW()")).
+		Where(sq.Eq{table + ".id": id, table + ".user_id": userId}).
+		Where(active)
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		logger.Trace("Revoking session")
+		tag, err := tx.Exec(s.ctx, sql, args...)
+		if err != nil {
+			err = db.ErrDoQuery(err)
+			logger.Error(err)
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+
+		return s.notify(tx, userId)
+	})
+}
+
+func (s *Storage) revoke(tx pgx.Tx, id uint64) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("revoked_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Revoking session")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
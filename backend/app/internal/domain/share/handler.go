@@ -0,0 +1,177 @@
+package share
+
+import (
+	"backend/internal/config"
+	"backend/pkg/auth"
+	"backend/pkg/jobs"
+	"backend/pkg/logging"
+	"backend/pkg/mailer"
+	"backend/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/julienschmidt/httprouter"
+)
+
+type Handler struct {
+	logger   *logging.Logger
+	storage  *Storage
+	ctx      context.Context
+	cfg      *config.Config
+	jobQueue *jobs.Queue
+}
+
+const (
+	sharesURL       = "/api/shares"
+	shareURL        = "/api/shares/:shareId"
+	acceptShareURL  = "/api/shares/accept"
+	sharedClientURL = "/api/shares/clients"
+)
+
+func NewShareHandler(ctx context.Context, storage *Storage, logger *logging.Logger, cfg *config.Config, jobQueue *jobs.Queue) *Handler {
+	return &Handler{
+		logger:   logger,
+		storage:  storage,
+		ctx:      ctx,
+		cfg:      cfg,
+		jobQueue: jobQueue,
+	}
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.GET(sharesURL, auth.RequireAuth(h.GetShares))
+	router.POST(sharesURL, auth.RequireAuth(h.CreateShare))
+	router.DELETE(shareURL, auth.RequireAuth(h.RevokeShare))
+	router.POST(acceptShareURL, auth.RequireAuth(h.AcceptShare))
+	router.GET(sharedClientURL, auth.RequireAuth(h.GetClients))
+}
+
+func (h *Handler) GetShares(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	shares, err := h.storage.All(userId)
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, shares)
+}
+
+func (h *Handler) GetClients(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	clients, err := h.storage.Clients(userId)
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, clients)
+}
+
+func (h *Handler) CreateShare(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var dto NewShareDto
+	userId := r.Context().Value("userId").(uint16)
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := json.Unmarshal(body, &dto); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// TODO проверить email на валидность - пока что, лишь бы не пустая строка
+	dto.Email = strings.TrimSpace(dto.Email)
+	if len(dto.Email) == 0 {
+		errorText := fmt.Sprintf("Invalid email: '%v'", dto.Email)
+		utils.WriteErrorResponse(w, http.StatusBadRequest, errorText)
+		return
+	}
+	if dto.ExpiresAt != nil && dto.ExpiresAt.Before(time.Now()) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Expiration date is in the past")
+		return
+	}
+
+	var mailErr error
+	shareId, err := h.storage.Create(userId, dto, func(tx pgx.Tx, token string, clientEmail string) error {
+		acceptLink := fmt.Sprintf("%v/shares/accept?token=%v", h.cfg.Frontend.ServerIP, token)
+
+		mail := mailer.Mail{
+			Username: dto.Email,
+			Subject:  "SMER diary invitation",
+			Text:     fmt.Sprintf("%v invites you to read their SMER diary. Accept link: %v", clientEmail, acceptLink),
+		}
+		_, mailErr = mailer.SendJob.EnqueueTx(h.jobQueue, tx, mail)
+		return mailErr
+	})
+	if errors.Is(err, ErrAlreadyShared) {
+		utils.WriteErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil && mailErr != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Mail error")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteResponse(w, http.StatusCreated, shareId)
+}
+
+func (h *Handler) AcceptShare(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var dto AcceptShareDto
+	userId := r.Context().Value("userId").(uint16)
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	shareId, err := h.storage.Accept(userId, dto.Token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, shareId)
+}
+
+func (h *Handler) RevokeShare(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("shareId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+	err = h.storage.Revoke(userId, uint16(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Share not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
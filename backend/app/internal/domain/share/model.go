@@ -0,0 +1,45 @@
+package share
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+type Share struct {
+	Id             uint16     `json:"id" sql:"id"`
+	ClientId       uint16     `json:"clientId" sql:"client_id"`
+	TherapistEmail string     `json:"therapistEmail" sql:"therapist_email"`
+	TherapistId    *uint16    `json:"therapistId" sql:"therapist_id"`
+	AcceptedAt     *time.Time `json:"acceptedAt" sql:"accepted_at"`
+	RevokedAt      *time.Time `json:"revokedAt" sql:"revoked_at"`
+	ExpiresAt      *time.Time `json:"expiresAt" sql:"expires_at"`
+	CreatedAt      time.Time  `json:"createdAt" sql:"created_at"`
+}
+
+// Client is a user who granted the therapist read access to their smers.
+type Client struct {
+	ShareId    uint16     `json:"shareId"`
+	Id         uint16     `json:"id"`
+	Email      string     `json:"email"`
+	Name       string     `json:"name"`
+	Surname    string     `json:"surname"`
+	AcceptedAt time.Time  `json:"acceptedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+type NewShareDto struct {
+	Email     string     `json:"email"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type AcceptShareDto struct {
+	Token string `json:"token"`
+}
+
+// hashToken is what is stored instead of the invitation token. Tokens are
+// long and random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
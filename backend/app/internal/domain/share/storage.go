@@ -0,0 +1,267 @@
+package share
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/dchest/uniuri"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewShareStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme     = "public"
+	table      = "shares"
+	usersTable = "users"
+)
+
+const (
+	tokenLength = 64
+)
+
+var ErrAlreadyShared = errors.New("smers are already shared with this email")
+
+// active limits shares to the accepted ones that are neither revoked nor expired.
+var active = sq.And{
+	sq.NotEq{"accepted_at": nil},
+	sq.Eq{"revoked_at": nil},
+	sq.Or{sq.Eq{"expires_at": nil}, sq.Expr("expires_at > NOW()")},
+}
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// All returns the shares created by the client, including revoked and expired ones.
+func (s *Storage) All(clientId uint16) ([]Share, error) {
+	query := s.queryBuilder.Select(
+		"id", "client_id", "therapist_email", "therapist_id", "accepted_at", "revoked_at", "expires_at", "created_at",
+	).
+		From(scheme + "." + table).
+		Where(sq.Eq{"client_id": clientId}).
+		OrderBy("created_at DESC")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Share, 0)
+
+	for rows.Next() {
+		p := Share{}
+		if err = rows.Scan(
+			&p.Id, &p.ClientId, &p.TherapistEmail, &p.TherapistId, &p.AcceptedAt, &p.RevokedAt, &p.ExpiresAt, &p.CreatedAt,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
+
+// Clients returns the users who actively share their smers with the therapist.
+func (s *Storage) Clients(therapistId uint16) ([]Client, error) {
+	query := s.queryBuilder.Select(
+		"s.id", "u.id", "u.email", "COALESCE(u.name, '')", "COALESCE(u.surname, '')", "s.accepted_at", "s.expires_at",
+	).
+		From(scheme + "." + table + " s").
+		Join(scheme + "." + usersTable + " u ON u.id = s.client_id").
+		Where(sq.Eq{"s.therapist_id": therapistId}).
+		Where(active).
+		OrderBy("s.accepted_at DESC")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Client, 0)
+
+	for rows.Next() {
+		p := Client{}
+		if err = rows.Scan(&p.ShareId, &p.Id, &p.Email, &p.Name, &p.Surname, &p.AcceptedAt, &p.ExpiresAt); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
+
+// Create invites the therapist by email. invite gets the invitation token and
+// the client's email within the transaction of the share, so the share is
+// only kept when the invitation is sent. Only a hash of the token is stored.
+func (s *Storage) Create(clientId uint16, dto NewShareDto, invite func(tx pgx.Tx, token string, clientEmail string) error) (uint16, error) {
+	lastInsertId := uint16(0)
+	token := uniuri.NewLen(tokenLength)
+	clientEmail := ""
+
+	// Expired shares would otherwise block a new invitation to the same email.
+	expireQuery := s.queryBuilder.Update(scheme+"."+table).
+		Set("revoked_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"client_id": clientId, "revoked_at": nil}).
+		Where("lower(therapist_email) = lower(?)", dto.Email).
+		Where("expires_at <= NOW()")
+
+	sql, args, err := expireQuery.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	query := s.queryBuilder.Insert(scheme+"."+table).
+		Columns("client_id", "therapist_email", "token_hash", "expires_at").
+		Values(clientId, dto.Email, hashToken(token), dto.ExpiresAt).
+		Suffix(fmt.Sprintf("RETURNING id, (SELECT email FROM %v.%v WHERE id = client_id)", scheme, usersTable))
+
+	insertSql, insertArgs, err := query.ToSql()
+	insertLogger := s.queryLogger(insertSql, table, insertArgs)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		insertLogger.Error(err)
+		return lastInsertId, err
+	}
+
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		logger.Trace("Revoking expired shares")
+		if _, err := tx.Exec(s.ctx, sql, args...); err != nil {
+			logger.Error(err)
+			return err
+		}
+
+		insertLogger.Trace("Creating share")
+		err := tx.QueryRow(s.ctx, insertSql, insertArgs...).Scan(&lastInsertId, &clientEmail)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrAlreadyShared
+			}
+			insertLogger.Error(err)
+			return err
+		}
+
+		return invite(tx, token, clientEmail)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastInsertId, nil
+}
+
+// Accept binds the invitation to the therapist. The invitation can only be accepted
+// by the user it was sent to. Returns pgx.ErrNoRows for unknown or stale tokens.
+func (s *Storage) Accept(therapistId uint16, token string) (uint16, error) {
+	var shareId uint16
+
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("therapist_id", therapistId).
+		Set("accepted_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"token_hash": hashToken(token), "accepted_at": nil, "revoked_at": nil}).
+		Where(sq.Or{sq.Eq{"expires_at": nil}, sq.Expr("expires_at > NOW()")}).
+		Where(fmt.Sprintf("lower(therapist_email) = (SELECT lower(email) FROM %v.%v WHERE id = ?)", scheme, usersTable), therapistId).
+		Suffix("RETURNING id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return shareId, err
+	}
+
+	logger.Trace("Accepting share")
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&shareId); err != nil {
+		logger.Error(err)
+		return shareId, err
+	}
+
+	return shareId, nil
+}
+
+// Revoke ends the share. Both the client and the therapist are allowed to do it.
+func (s *Storage) Revoke(userId uint16, id uint16) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("revoked_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "revoked_at": nil}).
+		Where(sq.Or{sq.Eq{"client_id": userId}, sq.Eq{"therapist_id": userId}})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Revoking share")
+	result, err := s.client.Exec(s.ctx, sql, args...)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
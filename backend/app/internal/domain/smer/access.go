@@ -0,0 +1,45 @@
+package smer
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+const (
+	sharesTable = "shares"
+)
+
+// kept limits smers to the ones not moved to the trash.
+var kept = sq.Eq{table + ".deleted_at": nil}
+
+// owned limits smers to the ones written by the user.
+func owned(userId uint16) sq.Sqlizer {
+	return sq.And{sq.Eq{table + ".user_id": userId}, kept}
+}
+
+// trashed limits smers to the user's ones in the trash.
+func trashed(userId uint16) sq.Sqlizer {
+	return sq.And{sq.Eq{table + ".user_id": userId}, sq.NotEq{table + ".deleted_at": nil}}
+}
+
+// shared limits smers to the client's ones, provided that
+// the client granted the therapist an active share.
+func shared(therapistId uint16, clientId uint16) sq.Sqlizer {
+	return sq.And{sq.Eq{table + ".user_id": clientId}, kept, sharedWith(therapistId)}
+}
+
+// sharedWith limits smers to the ones of any client who granted
+// the therapist an active share.
+func sharedWith(therapistId uint16) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM %[1]v.%[2]v sh WHERE sh.client_id = %[3]v.user_id AND sh.therapist_id = ? "+
+			"AND sh.accepted_at IS NOT NULL AND sh.revoked_at IS NULL AND (sh.expires_at IS NULL OR sh.expires_at > NOW()))",
+		scheme, sharesTable, table,
+	), therapistId)
+}
+
+// readable limits smers to the ones the user wrote or can read through a share.
+func readable(userId uint16) sq.Sqlizer {
+	return sq.Or{owned(userId), sq.And{kept, sharedWith(userId)}}
+}
@@ -0,0 +1,166 @@
+package smer
+
+import (
+	"backend/pkg/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	commentsURL     = "/api/smers/:smerId/comments"
+	commentURL      = "/api/smers/:smerId/comments/:commentId"
+	commentEditsURL = "/api/smers/:smerId/comments/:commentId/edits"
+)
+
+func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	comments, err := h.storage.Comments(userId, uint16(smerId))
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, comments)
+}
+
+func (h *Handler) CreateComment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var dto NewCommentDto
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := json.Unmarshal(body, &dto); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(strings.TrimSpace(dto.Body)) == 0 {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Empty comment")
+		return
+	}
+
+	userId := r.Context().Value("userId").(uint16)
+	commentId, err := h.storage.CreateComment(userId, uint16(smerId), dto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer, thought or parent comment not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusCreated, commentId)
+}
+
+func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseUint(ps.ByName("commentId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var dto UpdateCommentDto
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := json.Unmarshal(body, &dto); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(strings.TrimSpace(dto.Body)) == 0 {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Empty comment")
+		return
+	}
+
+	userId := r.Context().Value("userId").(uint16)
+	err = h.storage.UpdateComment(userId, uint16(smerId), uint16(id), dto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseUint(ps.ByName("commentId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userId := r.Context().Value("userId").(uint16)
+	err = h.storage.DeleteComment(userId, uint16(smerId), uint16(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+func (h *Handler) GetCommentEdits(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseUint(ps.ByName("commentId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userId := r.Context().Value("userId").(uint16)
+	edits, err := h.storage.CommentEdits(userId, uint16(smerId), uint16(id))
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, edits)
+}
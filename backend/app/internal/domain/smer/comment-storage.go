@@ -0,0 +1,277 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	commentsTable     = "smer_comments"
+	commentEditsTable = "smer_comment_edits"
+	usersTable        = "users"
+)
+
+// commentsCount is the number of not deleted comments of the selected smer.
+var commentsCount = fmt.Sprintf(
+	"(SELECT COUNT(*) FROM %[1]v.%[2]v c WHERE c.smer_id = %[3]v.id AND c.deleted_at IS NULL) AS comments_count",
+	scheme, commentsTable, table,
+)
+
+// smerComments is a JSON array of all comments of the selected smer with their edits.
+var smerComments = fmt.Sprintf(
+	"(SELECT COALESCE(json_agg(json_build_object('id', c.id, 'smerId', c.smer_id, 'thought', c.thought_index, "+
+		"'parentId', c.parent_id, 'authorId', c.author_id, 'author', COALESCE(NULLIF(concat_ws(' ', u.name, u.surname), ''), u.email), "+
+		"'body', c.body, 'edits', (SELECT COUNT(*) FROM %[1]v.%[3]v e WHERE e.comment_id = c.id), "+
+		"'createdAt', c.created_at, 'updatedAt', c.updated_at, 'deletedAt', c.deleted_at, "+
+		"'history', (SELECT COALESCE(json_agg(json_build_object('body', e.body, 'createdAt', e.created_at) ORDER BY e.created_at), '[]') "+
+		"FROM %[1]v.%[3]v e WHERE e.comment_id = c.id)) ORDER BY c.created_at), '[]') "+
+		"FROM %[1]v.%[2]v c JOIN %[1]v.%[4]v u ON u.id = c.author_id WHERE c.smer_id = %[5]v.id) AS comments",
+	scheme, commentsTable, commentEditsTable, usersTable, table,
+)
+
+// commentedSmerReadable limits comments to the ones of smers readable by the user.
+func commentedSmerReadable(userId uint16) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("EXISTS (SELECT 1 FROM %[1]v.%[2]v WHERE %[2]v.id = %[3]v.smer_id AND ?)", scheme, table, commentsTable), readable(userId))
+}
+
+func (s *Storage) Comments(userId uint16, smerId uint16) ([]Comment, error) {
+	query := s.queryBuilder.Select(
+		commentsTable+".id",
+		commentsTable+".smer_id",
+		commentsTable+".thought_index",
+		commentsTable+".parent_id",
+		commentsTable+".author_id",
+		"COALESCE(NULLIF(concat_ws(' ', u.name, u.surname), ''), u.email)",
+		"CASE WHEN "+commentsTable+".deleted_at IS NULL THEN "+commentsTable+".body ELSE '' END",
+		fmt.Sprintf("(SELECT COUNT(*) FROM %v.%v e WHERE e.comment_id = %v.id)", scheme, commentEditsTable, commentsTable),
+		commentsTable+".created_at",
+		commentsTable+".updated_at",
+		commentsTable+".deleted_at",
+	).
+		From(scheme + "." + commentsTable).
+		Join(scheme + "." + usersTable + " u ON u.id = " + commentsTable + ".author_id").
+		Where(sq.Eq{commentsTable + ".smer_id": smerId}).
+		Where(commentedSmerReadable(userId)).
+		OrderBy(commentsTable + ".created_at")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, commentsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Comment, 0)
+
+	for rows.Next() {
+		p := Comment{}
+		if err = rows.Scan(
+			&p.Id, &p.SmerId, &p.Thought, &p.ParentId, &p.AuthorId, &p.Author, &p.Body, &p.Edits, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
+
+// CreateComment comments the smer readable by the user. Returns pgx.ErrNoRows when
+// the smer, the thought or the parent comment doesn't exist.
+func (s *Storage) CreateComment(userId uint16, smerId uint16, dto NewCommentDto) (uint16, error) {
+	lastInsertId := uint16(0)
+
+	source := sq.Select().
+		Column("id").
+		Column("?::smallint", dto.Thought).
+		Column("?::bigint", dto.ParentId).
+		Column("?::bigint", userId).
+		Column("?::text", dto.Body).
+		From(scheme + "." + table).
+		Where(sq.Eq{"id": smerId}).
+		Where(readable(userId))
+	if dto.Thought != nil {
+		source = source.Where("? < cardinality(thoughts)", *dto.Thought)
+	}
+	if dto.ParentId != nil {
+		source = source.Where(fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM %v.%v p WHERE p.id = ? AND p.smer_id = %v.id)", scheme, commentsTable, table,
+		), *dto.ParentId)
+	}
+
+	query := s.queryBuilder.Insert(scheme+"."+commentsTable).
+		Columns("smer_id", "thought_index", "parent_id", "author_id", "body").
+		Select(source).
+		Suffix("RETURNING id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, commentsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	logger.Trace("Creating comment")
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId); err != nil {
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	return lastInsertId, nil
+}
+
+// UpdateComment changes the body of the author's comment keeping the previous one
+// in the edit history. Returns pgx.ErrNoRows when there is no such comment.
+func (s *Storage) UpdateComment(userId uint16, smerId uint16, id uint16, dto UpdateCommentDto) error {
+	query := s.queryBuilder.Select("body").
+		From(scheme + "." + commentsTable).
+		Where(sq.Eq{"id": id, "smer_id": smerId, "author_id": userId, "deleted_at": nil}).
+		Where(commentedSmerReadable(userId)).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, commentsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		var body string
+
+		if err := tx.QueryRow(s.ctx, sql, args...).Scan(&body); err != nil {
+			logger.Error(err)
+			return err
+		}
+		if body == dto.Body {
+			return nil
+		}
+
+		editQuery := s.queryBuilder.Insert(scheme+"."+commentEditsTable).
+			Columns("comment_id", "body").
+			Values(id, body)
+
+		sql, args, err := editQuery.ToSql()
+		logger := s.queryLogger(sql, commentEditsTable, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return err
+		}
+
+		logger.Trace("Saving comment edit")
+		if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+			logger.Error(err)
+			return err
+		}
+
+		updateQuery := s.queryBuilder.Update(scheme+"."+commentsTable).
+			Set("body", dto.Body).
+			Where(sq.Eq{"id": id})
+
+		sql, args, err = updateQuery.ToSql()
+		logger = s.queryLogger(sql, commentsTable, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return err
+		}
+
+		logger.Trace("Updating comment")
+		if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+			logger.Error(err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// DeleteComment soft deletes the author's comment.
+// Returns pgx.ErrNoRows when there is no such comment.
+func (s *Storage) DeleteComment(userId uint16, smerId uint16, id uint16) error {
+	query := s.queryBuilder.Update(scheme+"."+commentsTable).
+		Set("deleted_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "smer_id": smerId, "author_id": userId, "deleted_at": nil}).
+		Where(commentedSmerReadable(userId))
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, commentsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Deleting comment")
+	result, err := s.client.Exec(s.ctx, sql, args...)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// CommentEdits returns the previous bodies of the comment, the latest first.
+func (s *Storage) CommentEdits(userId uint16, smerId uint16, id uint16) ([]CommentEdit, error) {
+	query := s.queryBuilder.Select("e.body", "e.created_at").
+		From(scheme + "." + commentEditsTable + " e").
+		Join(scheme + "." + commentsTable + " ON " + commentsTable + ".id = e.comment_id").
+		Where(sq.Eq{commentsTable + ".id": id, commentsTable + ".smer_id": smerId, commentsTable + ".deleted_at": nil}).
+		Where(commentedSmerReadable(userId)).
+		OrderBy("e.created_at DESC")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, commentEditsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]CommentEdit, 0)
+
+	for rows.Next() {
+		p := CommentEdit{}
+		if err = rows.Scan(&p.Body, &p.CreatedAt); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
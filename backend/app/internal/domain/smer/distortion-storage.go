@@ -0,0 +1,148 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	distortionsTable        = "distortions"
+	thoughtDistortionsTable = "thought_distortions"
+)
+
+var ErrUnknownDistortion = errors.New("unknown distortion")
+
+// smerDistortions is a JSON array of the selected smer's thought distortions.
+var smerDistortions = fmt.Sprintf(
+	"(SELECT COALESCE(json_agg(json_build_object('thought', g.thought_index, 'distortions', g.codes) ORDER BY g.thought_index), '[]') "+
+		"FROM (SELECT td.thought_index, array_agg(d.code ORDER BY d.id) AS codes "+
+		"FROM %[1]v.%[2]v td JOIN %[1]v.%[3]v d ON d.id = td.distortion_id "+
+		"WHERE td.smer_id = %[4]v.id GROUP BY td.thought_index) g) AS thought_distortions",
+	scheme, thoughtDistortionsTable, distortionsTable, table,
+)
+
+// distortionFilter matches smers having at least one thought tagged with the distortion code.
+func distortionFilter(code string) *db.Filter {
+	return db.NewExprFilter(sq.Expr(fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM %[1]v.%[2]v td JOIN %[1]v.%[3]v d ON d.id = td.distortion_id WHERE td.smer_id = %[4]v.id AND d.code = ?)",
+		scheme, thoughtDistortionsTable, distortionsTable, table,
+	), code))
+}
+
+func (s *Storage) distortions(smerId uint16) ([]ThoughtDistortions, error) {
+	query := s.queryBuilder.Select("td.thought_index", "array_agg(d.code ORDER BY d.id)").
+		From(scheme + "." + thoughtDistortionsTable + " td").
+		Join(scheme + "." + distortionsTable + " d ON d.id = td.distortion_id").
+		Where(sq.Eq{"td.smer_id": smerId}).
+		GroupBy("td.thought_index").
+		OrderBy("td.thought_index")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, thoughtDistortionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]ThoughtDistortions, 0)
+
+	for rows.Next() {
+		t := ThoughtDistortions{}
+		if err = rows.Scan(&t.Thought, &t.Distortions); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, t)
+	}
+
+	return list, nil
+}
+
+// replaceDistortions stores tags for every thought. When tags is nil (a client that
+// doesn't know about distortions) the existing tags are kept, except for the changed
+// thoughts (see changedThoughts): they would be moved to another thought otherwise.
+func (s *Storage) replaceDistortions(tx pgx.Tx, smerId uint16, changed []int, tags []ThoughtDistortions) error {
+	removeQuery := s.queryBuilder.Delete(scheme + "." + thoughtDistortionsTable).Where(sq.Eq{"smer_id": smerId})
+	if tags == nil {
+		removeQuery = removeQuery.Where(sq.Eq{"thought_index": changed})
+	}
+
+	sql, args, err := removeQuery.ToSql()
+	logger := s.queryLogger(sql, thoughtDistortionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Deleting thought distortions")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	for _, tag := range tags {
+		codes := uniqueCodes(tag.Distortions)
+		if len(codes) == 0 {
+			continue
+		}
+
+		insertQuery := s.queryBuilder.Insert(scheme+"."+thoughtDistortionsTable).
+			Columns("smer_id", "thought_index", "distortion_id").
+			Select(sq.Select().
+				Column("?::bigint", smerId).
+				Column("?::smallint", tag.Thought).
+				Column("id").
+				From(scheme + "." + distortionsTable).
+				Where(sq.Eq{"code": codes}))
+
+		sql, args, err = insertQuery.ToSql()
+		logger = s.queryLogger(sql, thoughtDistortionsTable, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return err
+		}
+
+		logger.Trace("Creating thought distortions")
+		result, err := tx.Exec(s.ctx, sql, args...)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+		if result.RowsAffected() != int64(len(codes)) {
+			return fmt.Errorf("%w in %v", ErrUnknownDistortion, codes)
+		}
+	}
+
+	return nil
+}
+
+func uniqueCodes(codes []string) []string {
+	seen := make(map[string]bool, len(codes))
+	result := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		result = append(result, code)
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	emotionsTable = "smer_emotions"
+)
+
+// smerEmotionRatings is a JSON array of the selected smer's rated emotions.
+var smerEmotionRatings = fmt.Sprintf(
+	"(SELECT COALESCE(json_agg(json_build_object('name', e.name, 'intensityBefore', e.intensity_before, "+
+		"'intensityAfter', e.intensity_after) ORDER BY e.position), '[]') "+
+		"FROM %[1]v.%[2]v e WHERE e.smer_id = %[3]v.id) AS emotion_ratings",
+	scheme, emotionsTable, table,
+)
+
+func (s *Storage) emotions(smerId uint16) ([]Emotion, error) {
+	query := s.queryBuilder.Select("name", "intensity_before", "intensity_after").
+		From(scheme + "." + emotionsTable).
+		Where(sq.Eq{"smer_id": smerId}).
+		OrderBy("position")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, emotionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Emotion, 0)
+
+	for rows.Next() {
+		e := Emotion{}
+		if err = rows.Scan(&e.Name, &e.IntensityBefore, &e.IntensityAfter); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, e)
+	}
+
+	return list, nil
+}
+
+// keepIntensities copies already stored intensities into emotions that came
+// without them, matching by name. Used when a client only sends plain names.
+func (s *Storage) keepIntensities(smerId uint16, emotions []Emotion) error {
+	stored, err := s.emotions(smerId)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]Emotion, len(stored))
+	for _, emotion := range stored {
+		byName[emotion.Name] = emotion
+	}
+
+	for i, emotion := range emotions {
+		if old, ok := byName[emotion.Name]; ok {
+			emotions[i].IntensityBefore = old.IntensityBefore
+			emotions[i].IntensityAfter = old.IntensityAfter
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) replaceEmotions(tx pgx.Tx, smerId uint16, emotions []Emotion) error {
+	removeQuery := s.queryBuilder.Delete(scheme + "." + emotionsTable).Where(sq.Eq{"smer_id": smerId})
+
+	sql, args, err := removeQuery.ToSql()
+	logger := s.queryLogger(sql, emotionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Deleting smer emotions")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	if len(emotions) == 0 {
+		return nil
+	}
+
+	insertQuery := s.queryBuilder.Insert(scheme+"."+emotionsTable).
+		Columns("smer_id", "position", "name", "intensity_before", "intensity_after")
+	for i, emotion := range emotions {
+		insertQuery = insertQuery.Values(smerId, i, emotion.Name, emotion.IntensityBefore, emotion.IntensityAfter)
+	}
+
+	sql, args, err = insertQuery.ToSql()
+	logger = s.queryLogger(sql, emotionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Creating smer emotions")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
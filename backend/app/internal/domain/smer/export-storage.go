@@ -0,0 +1,124 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Each streams the user's smers matching the filters to fn in creation order,
+// without loading them all into memory. Iteration stops on the first fn error.
+// Rated emotions, distortions and reframes come along with every smer.
+func (s *Storage) Each(userId uint16, filters []*db.Filter, fn func(Smer) error) error {
+	query := s.queryBuilder.Select(
+		"id",
+		"user_id",
+		"situation",
+		"thoughts",
+		"emotions",
+		"reactions",
+		"created_at",
+		"updated_at",
+		smerEmotionRatings,
+		smerDistortions,
+		smerReframes,
+	).From(scheme+"."+table).Where(owned(userId)).OrderBy("created_at", "id")
+
+	for _, filter := range filters {
+		query = filter.UseSelectBuilder(query)
+	}
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		p := Smer{}
+		if err = rows.Scan(
+			&p.Id, &p.UserId, &p.Situation, &p.Thoughts, &p.Emotions, &p.Reactions, &p.CreatedAt, &p.UpdatedAt,
+			&p.EmotionRatings, &p.ThoughtDistortions, &p.Reframes,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return err
+		}
+
+		if err = fn(p); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// EachArchived streams everything stored about the user's smers to fn in
+// creation order, trashed smers, comments and revisions included. It feeds
+// the account archive, Each stays limited to what the diary shows.
+func (s *Storage) EachArchived(userId uint16, fn func(ArchivedSmer) error) error {
+	query := s.queryBuilder.Select(
+		"id",
+		"user_id",
+		"situation",
+		"thoughts",
+		"emotions",
+		"reactions",
+		"created_at",
+		"updated_at",
+		"deleted_at",
+		commentsCount,
+		smerTags,
+		smerEmotionRatings,
+		smerDistortions,
+		smerReframes,
+		smerComments,
+		smerRevisions,
+	).From(scheme+"."+table).Where(sq.Eq{table + ".user_id": userId}).OrderBy("created_at", "id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		p := ArchivedSmer{}
+		if err = rows.Scan(
+			&p.Id, &p.UserId, &p.Situation, &p.Thoughts, &p.Emotions, &p.Reactions, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt,
+			&p.CommentsCount, &p.Tags, &p.EmotionRatings, &p.ThoughtDistortions, &p.Reframes, &p.Comments, &p.Revisions,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return err
+		}
+
+		if err = fn(p); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,242 @@
+package smer
+
+import (
+	"backend/pkg/pdf"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatPDF  = "pdf"
+)
+
+// csvListSeparator joins array columns inside a single CSV cell.
+const csvListSeparator = "\n"
+
+var csvHeader = []string{
+	"id", "createdAt", "updatedAt", "situation", "thoughts", "emotions", "reactions",
+	"emotionRatings", "distortions", "reframes",
+}
+
+// maxPDFRows caps the PDF export: the document is built in memory before it is
+// written out, CSV and JSON stream any number of smers.
+const maxPDFRows = 1000
+
+// errPDFFull stops the export once the PDF has maxPDFRows smers.
+var errPDFFull = errors.New("the PDF export is full")
+
+// exporter writes smers one by one in a particular format.
+type exporter interface {
+	Write(smer Smer) error
+	Close() error
+}
+
+var contentTypes = map[string]string{
+	FormatCSV:  "text/csv; charset=UTF-8",
+	FormatJSON: "application/json; charset=UTF-8",
+	FormatPDF:  "application/pdf",
+}
+
+func newExporter(format string, w io.Writer, title string) exporter {
+	switch format {
+	case FormatCSV:
+		return newCSVExporter(w)
+	case FormatJSON:
+		return &jsonExporter{w: w}
+	case FormatPDF:
+		return newPDFExporter(w, title)
+	}
+	return nil
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) *csvExporter {
+	e := &csvExporter{w: csv.NewWriter(w)}
+	e.w.Write(csvHeader)
+	return e
+}
+
+func (e *csvExporter) Write(smer Smer) error {
+	return e.w.Write([]string{
+		strconv.FormatUint(uint64(smer.Id), 10),
+		smer.CreatedAt.Format(time.RFC3339),
+		smer.UpdatedAt.Format(time.RFC3339),
+		smer.Situation,
+		strings.Join(smer.Thoughts, csvListSeparator),
+		strings.Join(smer.Emotions, csvListSeparator),
+		strings.Join(smer.Reactions, csvListSeparator),
+		strings.Join(ratingLines(smer), csvListSeparator),
+		strings.Join(distortionLines(smer), csvListSeparator),
+		strings.Join(reframeLines(smer), csvListSeparator),
+	})
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonExporter writes a JSON array element by element.
+type jsonExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExporter) Write(smer Smer) error {
+	prefix := ","
+	if e.count == 0 {
+		prefix = "["
+	}
+	e.count++
+
+	if _, err := io.WriteString(e.w, prefix); err != nil {
+		return err
+	}
+	return json.NewEncoder(e.w).Encode(smer)
+}
+
+func (e *jsonExporter) Close() error {
+	closing := "]"
+	if e.count == 0 {
+		closing = "[]"
+	}
+	_, err := io.WriteString(e.w, closing)
+	return err
+}
+
+// pdfExporter renders rows as they come, the document itself
+// is written out once it is complete. It takes up to maxPDFRows smers.
+type pdfExporter struct {
+	w     io.Writer
+	table *pdf.Table
+	rows  int
+}
+
+func newPDFExporter(w io.Writer, title string) *pdfExporter {
+	return &pdfExporter{
+		w: w,
+		table: pdf.NewTable(title, []pdf.Column{
+			{Title: "Date", Width: 27},
+			{Title: "Situation", Width: 70},
+			{Title: "Thoughts", Width: 70},
+			{Title: "Emotions", Width: 50},
+			{Title: "Reactions", Width: 60},
+		}),
+	}
+}
+
+func (e *pdfExporter) Write(smer Smer) error {
+	if e.rows == maxPDFRows {
+		e.table.AddRow([]string{"", fmt.Sprintf(
+			"Only the first %d entries fit into a PDF, export CSV or JSON to get all of them.", maxPDFRows,
+		)})
+		return errPDFFull
+	}
+	e.rows++
+
+	emotions := bullets(smer.Emotions)
+	if len(smer.EmotionRatings) > 0 {
+		emotions = bullets(ratingLines(smer))
+	}
+
+	e.table.AddRow([]string{
+		smer.CreatedAt.Format("2006-01-02 15:04"),
+		smer.Situation,
+		pdfThoughts(smer),
+		emotions,
+		bullets(smer.Reactions),
+	})
+	return nil
+}
+
+// pdfThoughts lists the thoughts with their distortions and reframes under each of them.
+func pdfThoughts(smer Smer) string {
+	distortions := make(map[int][]string, len(smer.ThoughtDistortions))
+	for _, tags := range smer.ThoughtDistortions {
+		distortions[tags.Thought] = tags.Distortions
+	}
+	reframes := make(map[int]Reframe, len(smer.Reframes))
+	for _, reframe := range smer.Reframes {
+		reframes[reframe.Thought] = reframe
+	}
+
+	lines := make([]string, 0, len(smer.Thoughts))
+	for i, thought := range smer.Thoughts {
+		lines = append(lines, "• "+thought)
+		if codes, ok := distortions[i]; ok {
+			lines = append(lines, "   distortions: "+strings.Join(codes, ", "))
+		}
+		if reframe, ok := reframes[i]; ok {
+			lines = append(lines, "   → "+reframeText(reframe))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ratingLines reads "name: before → after", a missing intensity is a dash.
+func ratingLines(smer Smer) []string {
+	lines := make([]string, len(smer.EmotionRatings))
+	for i, emotion := range smer.EmotionRatings {
+		lines[i] = fmt.Sprintf("%v: %v → %v", emotion.Name, intensityText(emotion.IntensityBefore), intensityText(emotion.IntensityAfter))
+	}
+	return lines
+}
+
+func intensityText(intensity *int16) string {
+	if intensity == nil {
+		return "–"
+	}
+	return strconv.Itoa(int(*intensity))
+}
+
+// distortionLines reads "thought N: code, code", thoughts are numbered from 1.
+func distortionLines(smer Smer) []string {
+	lines := make([]string, len(smer.ThoughtDistortions))
+	for i, tags := range smer.ThoughtDistortions {
+		lines[i] = fmt.Sprintf("thought %d: %v", tags.Thought+1, strings.Join(tags.Distortions, ", "))
+	}
+	return lines
+}
+
+// reframeLines reads "thought N: alternative | for: … | against: …".
+func reframeLines(smer Smer) []string {
+	lines := make([]string, len(smer.Reframes))
+	for i, reframe := range smer.Reframes {
+		lines[i] = fmt.Sprintf("thought %d: %v", reframe.Thought+1, reframeText(reframe))
+	}
+	return lines
+}
+
+func reframeText(reframe Reframe) string {
+	parts := []string{reframe.AlternativeThought}
+	if len(reframe.EvidenceFor) > 0 {
+		parts = append(parts, "for: "+strings.Join(reframe.EvidenceFor, "; "))
+	}
+	if len(reframe.EvidenceAgainst) > 0 {
+		parts = append(parts, "against: "+strings.Join(reframe.EvidenceAgainst, "; "))
+	}
+	return strings.Join(parts, " | ")
+}
+
+func (e *pdfExporter) Close() error {
+	return e.table.Output(e.w)
+}
+
+func bullets(items []string) string {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = "• " + item
+	}
+	return strings.Join(lines, "\n")
+}
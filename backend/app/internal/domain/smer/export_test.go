@@ -0,0 +1,118 @@
+package smer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func intensity(value int16) *int16 {
+	return &value
+}
+
+var exportedSmer = Smer{
+	Id:        7,
+	Situation: "Meeting",
+	Thoughts:  []string{"They hate me", "I will fail"},
+	Emotions:  []string{"anxiety", "shame"},
+	Reactions: []string{"silence"},
+	CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	UpdatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	EmotionRatings: []Emotion{
+		{Name: "anxiety", IntensityBefore: intensity(80), IntensityAfter: intensity(30)},
+		{Name: "shame", IntensityBefore: intensity(50)},
+	},
+	ThoughtDistortions: []ThoughtDistortions{
+		{Thought: 1, Distortions: []string{"catastrophizing", "fortune_telling"}},
+	},
+	Reframes: []Reframe{
+		{Thought: 0, AlternativeThought: "They were busy", EvidenceAgainst: []string{"they smiled"}},
+	},
+}
+
+func TestCSVExporter(t *testing.T) {
+	var buffer bytes.Buffer
+	e := newCSVExporter(&buffer)
+	if err := e.Write(exportedSmer); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %v, want the header and a row", len(records))
+	}
+	if !reflect.DeepEqual(records[0], csvHeader) {
+		t.Errorf("header = %v, want %v", records[0], csvHeader)
+	}
+
+	want := []string{
+		"7", "2026-01-02T03:04:05Z", "2026-01-02T03:04:05Z", "Meeting",
+		"They hate me\nI will fail", "anxiety\nshame", "silence",
+		"anxiety: 80 → 30\nshame: 50 → –",
+		"thought 2: catastrophizing, fortune_telling",
+		"thought 1: They were busy | against: they smiled",
+	}
+	if !reflect.DeepEqual(records[1], want) {
+		t.Errorf("row = %q, want %q", records[1], want)
+	}
+}
+
+// TestCSVExportImports checks that an exported file can be imported back.
+func TestCSVExportImports(t *testing.T) {
+	var buffer bytes.Buffer
+	e := newCSVExporter(&buffer)
+	e.Write(exportedSmer)
+	e.Close()
+
+	rows, err := parseCSVImport(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].err != nil {
+		t.Fatalf("rows = %+v", rows)
+	}
+	smer := rows[0].smer
+	if smer.Situation != exportedSmer.Situation || !reflect.DeepEqual(smer.Thoughts, exportedSmer.Thoughts) ||
+		!smer.CreatedAt.Equal(exportedSmer.CreatedAt) {
+		t.Errorf("imported %+v", smer)
+	}
+}
+
+func TestPDFThoughts(t *testing.T) {
+	want := "• They hate me\n   → They were busy | against: they smiled\n" +
+		"• I will fail\n   distortions: catastrophizing, fortune_telling"
+	if got := pdfThoughts(exportedSmer); got != want {
+		t.Errorf("pdfThoughts = %q, want %q", got, want)
+	}
+}
+
+func TestPDFExporterIsCapped(t *testing.T) {
+	e := newPDFExporter(io.Discard, "test")
+
+	var err error
+	written := 0
+	for i := 0; i <= maxPDFRows && err == nil; i++ {
+		if err = e.Write(exportedSmer); err == nil {
+			written++
+		}
+	}
+	if !errors.Is(err, errPDFFull) {
+		t.Fatalf("err = %v, want %v", err, errPDFFull)
+	}
+	if written != maxPDFRows {
+		t.Errorf("written = %v, want %v", written, maxPDFRows)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
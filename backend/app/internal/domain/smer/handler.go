@@ -7,11 +7,17 @@ import (
 	"backend/pkg/utils"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
 	"github.com/julienschmidt/httprouter"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Handler struct {
@@ -23,6 +29,50 @@ type Handler struct {
 const (
 	smersURL = "/api/smers"
 	smerURL  = "/api/smers/:smerId"
+
+	reframeURL = "/api/smers/:smerId/reframes/:thought"
+	restoreURL = "/api/smers/:smerId/restore"
+
+	sharedSmersURL = "/api/shares/clients/:clientId/smers"
+	sharedSmerURL  = "/api/shares/clients/:clientId/smers/:smerId"
+)
+
+// listFields are the columns list endpoints can be filtered and sorted by.
+var listFields = model.Fields{
+	"situation": {Column: table + ".situation", Type: model.FieldText},
+	"thoughts":  {Column: table + ".thoughts", Type: model.FieldArray},
+	"emotions":  {Column: table + ".emotions", Type: model.FieldArray},
+	"reactions": {Column: table + ".reactions", Type: model.FieldArray},
+	"createdAt": {Column: table + ".created_at", Type: model.FieldTime},
+	"updatedAt": {Column: table + ".updated_at", Type: model.FieldTime},
+}
+
+// trashFields are listFields plus the deletion time, which is only set in the trash.
+var trashFields = func() model.Fields {
+	fields := model.Fields{
+		"deletedAt": {Column: table + ".deleted_at", Type: model.FieldTime},
+	}
+	for name, field := range listFields {
+		fields[name] = field
+	}
+	return fields
+}()
+
+const (
+	searchSegment = "search"
+	statsSegment  = "stats"
+	exportSegment = "export"
+	importSegment = "import"
+	trashSegment  = "trash"
+)
+
+const (
+	defaultStatsPeriod = 30 * 24 * time.Hour
+	defaultStatsTop    = 5
+
+	maxImportSize = 10 * 1048576
+
+	dateLayout = "2006-01-02"
 )
 
 func NewSmerHandler(ctx context.Context, storage *Storage, logger *logging.Logger) *Handler {
@@ -36,9 +86,33 @@ func NewSmerHandler(ctx context.Context, storage *Storage, logger *logging.Logge
 func (h *Handler) Register(router *httprouter.Router) {
 	router.GET(smersURL, auth.RequireAuth(h.GetSmers))
 	router.POST(smersURL, auth.RequireAuth(h.CreateSmer))
-	router.GET(smerURL, auth.RequireAuth(h.GetSmer))
+	router.GET(smerURL, auth.RequireAuth(utils.StaticSegments("smerId", map[string]httprouter.Handle{
+		searchSegment: h.SearchSmers,
+		statsSegment:  h.GetStats,
+		exportSegment: h.ExportSmers,
+		trashSegment:  h.GetTrash,
+	}, h.GetSmer)))
+	router.POST(smerURL, auth.RequireAuth(utils.StaticSegments("smerId", map[string]httprouter.Handle{
+		importSegment: h.ImportSmers,
+	}, nil)))
 	router.PATCH(smerURL, auth.RequireAuth(h.UpdateSmer))
 	router.DELETE(smerURL, auth.RequireAuth(h.DeleteSmer))
+	router.POST(restoreURL, auth.RequireAuth(h.RestoreSmer))
+
+	router.PATCH(reframeURL, auth.RequireAuth(h.PatchReframe))
+
+	router.GET(commentsURL, auth.RequireAuth(h.GetComments))
+	router.POST(commentsURL, auth.RequireAuth(h.CreateComment))
+	router.PATCH(commentURL, auth.RequireAuth(h.UpdateComment))
+	router.DELETE(commentURL, auth.RequireAuth(h.DeleteComment))
+	router.GET(commentEditsURL, auth.RequireAuth(h.GetCommentEdits))
+
+	router.GET(revisionsURL, auth.RequireAuth(h.GetRevisions))
+	router.GET(revisionsDiffURL, auth.RequireAuth(h.DiffRevisions))
+	router.POST(revisionRestoreURL, auth.RequireAuth(h.RestoreRevision))
+
+	router.GET(sharedSmersURL, auth.RequireAuth(h.GetSharedSmers))
+	router.GET(sharedSmerURL, auth.RequireAuth(h.GetSharedSmer))
 }
 
 func (h *Handler) GetSmers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -47,14 +121,86 @@ func (h *Handler) GetSmers(w http.ResponseWriter, r *http.Request, ps httprouter
 	userId := r.Context().Value("userId").(uint16)
 	//h.storage.ctx = context.WithValue(h.storage.ctx, "userId", userId)
 
-	// TODO withfilters
-	// TODO withsorts
 	pagination, err := model.NewPagination(r)
-	sorts, err := model.NewSorts(r)
-	filters, err := model.NewFilters(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sorts, err := model.NewSorts(r, listFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filters, err := model.NewFilters(r, listFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if code := r.URL.Query().Get("distortion"); code != "" {
+		filters = append(filters, distortionFilter(code))
+	}
+	tags, err := tagFilters(r.URL.Query())
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filters = append(filters, tags...)
 	h.logger.Trace(filters)
 
 	smers, meta, err := h.storage.All(userId, filters, pagination, sorts...)
+	if errors.Is(err, model.ErrInvalidCursor) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, utils.MetaData{
+		Data: smers,
+		Meta: meta,
+	})
+}
+
+func (h *Handler) GetSharedSmers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	clientId, err := strconv.ParseUint(ps.ByName("clientId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	pagination, err := model.NewPagination(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sorts, err := model.NewSorts(r, listFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filters, err := model.NewFilters(r, listFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if code := r.URL.Query().Get("distortion"); code != "" {
+		filters = append(filters, distortionFilter(code))
+	}
+	tags, err := tagFilters(r.URL.Query())
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filters = append(filters, tags...)
+
+	smers, meta, err := h.storage.AllShared(userId, uint16(clientId), filters, pagination, sorts...)
+	if errors.Is(err, model.ErrInvalidCursor) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error(err)
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
@@ -66,6 +212,255 @@ func (h *Handler) GetSmers(w http.ResponseWriter, r *http.Request, ps httprouter
 	})
 }
 
+func (h *Handler) GetSharedSmer(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	clientId, err := strconv.ParseUint(ps.ByName("clientId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+	smer, err := h.storage.GetShared(userId, uint16(clientId), uint16(id))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, smer)
+}
+
+func (h *Handler) SearchSmers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(text) == 0 {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Empty search query")
+		return
+	}
+
+	pagination, err := model.NewPagination(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, meta, err := h.storage.Search(userId, text, pagination)
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, utils.MetaData{
+		Data: results,
+		Meta: meta,
+	})
+}
+
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	params, err := newStatsParams(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := h.storage.Stats(userId, *params)
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, stats)
+}
+
+func (h *Handler) ExportSmers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+	queryValues := r.URL.Query()
+
+	format := queryValues.Get("format")
+	if format == "" {
+		format = FormatCSV
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid format: '%v'", format))
+		return
+	}
+
+	filters, err := model.NewFilters(r, listFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if code := queryValues.Get("distortion"); code != "" {
+		filters = append(filters, distortionFilter(code))
+	}
+	tags, err := tagFilters(queryValues)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filters = append(filters, tags...)
+
+	title := "SMER diary"
+	if from := queryValues.Get("from"); from != "" {
+		date, err := parseDate(from, time.UTC)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid from: '%v'", from))
+			return
+		}
+		filters = append(filters, model.NewFilter("created_at", model.FilterTypeGTE, date))
+		title += " from " + from
+	}
+	if to := queryValues.Get("to"); to != "" {
+		date, err := parseDate(to, time.UTC)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid to: '%v'", to))
+			return
+		}
+		if len(to) == len(dateLayout) {
+			date = date.AddDate(0, 0, 1)
+		}
+		filters = append(filters, model.NewFilter("created_at", model.FilterTypeLT, date))
+		title += " to " + to
+	}
+
+	filename := fmt.Sprintf("smers-%v.%v", time.Now().Format(dateLayout), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// The status is sent with the first written byte, so errors past
+	// this point can only be logged.
+	e := newExporter(format, w, title)
+	err = h.storage.Each(userId, filters, e.Write)
+	if err != nil && !errors.Is(err, errPDFFull) {
+		h.logger.Error(err)
+	}
+	if err = e.Close(); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+func (h *Handler) ImportSmers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+	queryValues := r.URL.Query()
+
+	format := queryValues.Get("format")
+	if format == "" {
+		format = FormatJSON
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			format = FormatCSV
+		}
+	}
+	dryRun, _ := strconv.ParseBool(queryValues.Get("dryRun"))
+
+	defer r.Body.Close()
+	rows, err := parseImport(format, io.LimitReader(r.Body, maxImportSize))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := h.storage.Import(userId, rows, dryRun)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	utils.WriteResponse(w, status, report)
+}
+
+// tagFilters reads ?tag= (repeatable), smers must have all the tags.
+func tagFilters(queryValues url.Values) ([]*model.Filter, error) {
+	filters := make([]*model.Filter, 0)
+	for _, value := range queryValues["tag"] {
+		tagId, err := strconv.ParseUint(value, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tag: '%v'", value)
+		}
+		filters = append(filters, tagFilter(uint16(tagId)))
+	}
+	return filters, nil
+}
+
+func newStatsParams(r *http.Request) (*StatsParams, error) {
+	queryValues := r.URL.Query()
+
+	params := &StatsParams{
+		To:       time.Now(),
+		Bucket:   queryValues.Get("bucket"),
+		Location: time.UTC,
+		Top:      defaultStatsTop,
+	}
+
+	switch params.Bucket {
+	case "":
+		params.Bucket = BucketDay
+	case BucketDay, BucketWeek, BucketMonth:
+	default:
+		return nil, fmt.Errorf("Invalid bucket: '%v'", params.Bucket)
+	}
+
+	if tz := queryValues.Get("tz"); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tz: '%v'", tz)
+		}
+		params.Location = location
+	}
+
+	if to := queryValues.Get("to"); to != "" {
+		date, err := parseDate(to, params.Location)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid to: '%v'", to)
+		}
+		params.To = date
+		if len(to) == len(dateLayout) {
+			// a plain date includes the whole day
+			params.To = date.AddDate(0, 0, 1)
+		}
+	}
+
+	params.From = params.To.Add(-defaultStatsPeriod)
+	if from := queryValues.Get("from"); from != "" {
+		date, err := parseDate(from, params.Location)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid from: '%v'", from)
+		}
+		params.From = date
+	}
+	if !params.From.Before(params.To) {
+		return nil, fmt.Errorf("Invalid period: from must be before to")
+	}
+
+	if top := queryValues.Get("top"); top != "" {
+		value, err := strconv.ParseUint(top, 10, 64)
+		if err != nil || value == 0 {
+			return nil, fmt.Errorf("Invalid top: '%v'", top)
+		}
+		params.Top = value
+	}
+
+	return params, nil
+}
+
+// parseDate accepts both RFC 3339 timestamps and plain dates,
+// the latter are taken at midnight in the given location.
+func parseDate(value string, location *time.Location) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	return time.ParseInLocation(dateLayout, value, location)
+}
+
 func (h *Handler) GetSmer(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	id, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
 	if err != nil {
@@ -97,7 +492,16 @@ func (h *Handler) CreateSmer(w http.ResponseWriter, r *http.Request, _ httproute
 		return
 	}
 
+	if err := smer.Validate(); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	smerId, err := h.storage.Create(smer, userId)
+	if errors.Is(err, ErrUnknownDistortion) || errors.Is(err, ErrUnknownTag) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -125,8 +529,21 @@ func (h *Handler) UpdateSmer(w http.ResponseWriter, r *http.Request, ps httprout
 		return
 	}
 
+	if err := smer.Validate(); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	userId := r.Context().Value("userId").(uint16)
 	err = h.storage.Update(userId, uint16(id), smer)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer not found")
+		return
+	}
+	if errors.Is(err, ErrUnknownDistortion) || errors.Is(err, ErrUnknownTag) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -142,9 +559,105 @@ func (h *Handler) DeleteSmer(w http.ResponseWriter, r *http.Request, ps httprout
 	}
 	userId := r.Context().Value("userId").(uint16)
 	err = h.storage.Delete(userId, uint16(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer not found")
+		return
+	}
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	utils.WriteResponse(w, http.StatusOK, id)
 }
+
+func (h *Handler) RestoreSmer(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+	err = h.storage.Restore(userId, uint16(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer not found in the trash")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+func (h *Handler) GetTrash(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	pagination, err := model.NewPagination(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filters, err := model.NewFilters(r, trashFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sorts, err := model.NewSorts(r, trashFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	smers, meta, err := h.storage.Trash(userId, filters, pagination, sorts...)
+	if errors.Is(err, model.ErrInvalidCursor) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, utils.MetaData{
+		Data: smers,
+		Meta: meta,
+	})
+}
+
+func (h *Handler) PatchReframe(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	thought, err := strconv.Atoi(ps.ByName("thought"))
+	if err != nil || thought < 0 {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid thought: '%v'", ps.ByName("thought")))
+		return
+	}
+
+	var patch ReframePatchDto
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := json.Unmarshal(body, &patch); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userId := r.Context().Value("userId").(uint16)
+	reframe, err := h.storage.PatchReframe(userId, uint16(id), thought, patch)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer or thought not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, reframe)
+}
@@ -0,0 +1,82 @@
+package smer
+
+import (
+	"backend/pkg/client/postgresql/model"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewStatsParams(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		query string
+		from  time.Time
+		to    time.Time
+		err   bool
+	}{
+		{query: "from=2026-01-01&to=2026-01-31", from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{query: "from=2026-01-01&to=2026-01-01", from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{query: "tz=Europe/Moscow&from=2026-01-01&to=2026-01-01", from: time.Date(2026, 1, 1, 0, 0, 0, 0, moscow), to: time.Date(2026, 1, 2, 0, 0, 0, 0, moscow)},
+		{query: "from=2026-02-01&to=2026-01-01", err: true},
+		{query: "from=2026-01-01T10:00:00Z&to=2026-01-01T10:00:00Z", err: true},
+		{query: "bucket=year", err: true},
+		{query: "tz=Nowhere/Land", err: true},
+		{query: "top=0", err: true},
+	}
+
+	for _, test := range tests {
+		params, err := newStatsParams(httptest.NewRequest("GET", "/api/smers/stats?"+test.query, nil))
+		if test.err {
+			if err == nil {
+				t.Errorf("%v: no error", test.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.query, err)
+			continue
+		}
+		if !params.From.Equal(test.from) || !params.To.Equal(test.to) {
+			t.Errorf("%v: period = %v - %v, want %v - %v", test.query, params.From, params.To, test.from, test.to)
+		}
+	}
+}
+
+func TestListFields(t *testing.T) {
+	tests := []struct {
+		column string
+		op     string
+		value  string
+		valid  bool
+	}{
+		{"situation", "ilike", "work", true},
+		{"thoughts", "overlaps", "nobody", true},
+		{"emotions", "contains", "fear", true},
+		{"reactions", "ilike", "cry", true},
+		{"createdAt", "gte", "2026-01-01", true},
+		{"updatedAt", "lt", "2026-01-01", true},
+		{"userId", "eq", "1", false},
+		{"user_id", "eq", "1", false},
+		{"id", "eq", "1", false},
+		{"thoughts", "eq", "a", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/smers?filter[0][column]="+test.column+
+			"&filter[0][op]="+test.op+"&filter[0][value]="+test.value, nil)
+
+		_, err := model.NewFilters(r, listFields)
+		if test.valid && err != nil {
+			t.Errorf("%v %v: NewFilters error = %v", test.column, test.op, err)
+		}
+		if !test.valid && !errors.Is(err, model.ErrInvalidFilter) {
+			t.Errorf("%v %v: NewFilters error = %v, want ErrInvalidFilter", test.column, test.op, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package smer
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// errDryRun rolls back the import transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// Import inserts valid rows in a single transaction and reports every row.
+// Each row gets its own savepoint, so a row rejected by the database doesn't
+// abort the others. A dry run goes through the same steps and rolls back.
+func (s *Storage) Import(userId uint16, rows []importRow, dryRun bool) (*ImportReport, error) {
+	var report *ImportReport
+
+	err := s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		report = &ImportReport{
+			DryRun: dryRun,
+			Rows:   make([]ImportRow, 0, len(rows)),
+		}
+
+		for i, row := range rows {
+			result := ImportRow{Row: i + 1, Status: ImportStatusRejected}
+
+			if row.err != nil {
+				result.Errors = []string{row.err.Error()}
+			} else {
+				result.Errors = validateImport(row.smer)
+			}
+
+			if len(result.Errors) == 0 {
+				err := tx.BeginFunc(s.ctx, func(savepoint pgx.Tx) error {
+					id, err := s.create(savepoint, row.smer, userId)
+					if err == nil {
+						result.Id = &id
+					}
+					return err
+				})
+				if err != nil {
+					result.Errors = []string{err.Error()}
+				}
+			}
+
+			if len(result.Errors) == 0 && dryRun {
+				result.Status = ImportStatusValid
+				result.Id = nil
+				report.Valid++
+			} else if len(result.Errors) == 0 {
+				result.Status = ImportStatusCreated
+				report.Created++
+			} else {
+				result.Id = nil
+				report.Rejected++
+			}
+
+			report.Rows = append(report.Rows, result)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errDryRun) {
+		s.logger.Error(err)
+		return nil, err
+	}
+
+	return report, nil
+}
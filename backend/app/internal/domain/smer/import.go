@@ -0,0 +1,135 @@
+package smer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// importRow is a parsed row, err is set when the row couldn't even be read.
+type importRow struct {
+	smer Smer
+	err  error
+}
+
+func parseImport(format string, r io.Reader) ([]importRow, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSVImport(r)
+	case FormatJSON:
+		return parseJSONImport(r)
+	}
+	return nil, fmt.Errorf("Invalid format: '%v'", format)
+}
+
+func parseJSONImport(r io.Reader) ([]importRow, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	rows := make([]importRow, len(items))
+	for i, item := range items {
+		rows[i].err = json.Unmarshal(item, &rows[i].smer)
+	}
+	return rows, nil
+}
+
+// parseCSVImport reads the same layout the CSV export writes.
+// Only the situation column is required, unknown columns are ignored.
+// A row with another number of fields than the header is rejected alone.
+func parseCSVImport(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["situation"]; !ok {
+		return nil, errors.New("CSV header has no 'situation' column")
+	}
+
+	rows := make([]importRow, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+				rows = append(rows, importRow{err: err})
+				continue
+			}
+			return nil, err
+		}
+
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := importRow{
+			smer: Smer{
+				Situation: cell("situation"),
+				Thoughts:  splitList(cell("thoughts")),
+				Emotions:  splitList(cell("emotions")),
+				Reactions: splitList(cell("reactions")),
+			},
+		}
+		if createdAt := cell("createdAt"); createdAt != "" {
+			row.smer.CreatedAt, row.err = time.Parse(time.RFC3339, createdAt)
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func splitList(cell string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(cell, csvListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// validateImport checks a row against the smer shape before it gets to the database.
+// Emotions may come as plain names or as ratings only.
+func validateImport(smer Smer) []string {
+	smer.syncEmotions()
+
+	errs := make([]string, 0)
+	if strings.TrimSpace(smer.Situation) == "" {
+		errs = append(errs, "situation is empty")
+	}
+	if smer.Thoughts == nil {
+		errs = append(errs, "thoughts are missing")
+	}
+	if smer.Emotions == nil {
+		errs = append(errs, "emotions are missing")
+	}
+	if smer.Reactions == nil {
+		errs = append(errs, "reactions are missing")
+	}
+	if smer.CreatedAt.After(time.Now()) {
+		errs = append(errs, "createdAt is in the future")
+	}
+	if err := smer.Validate(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
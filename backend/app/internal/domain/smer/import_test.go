@@ -0,0 +1,128 @@
+package smer
+
+import (
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSVImport(t *testing.T) {
+	input := "situation,thoughts,emotions,reactions,createdAt,unknown\n" +
+		"Meeting,\"They hate me\nI will fail\",anxiety,silence,2026-01-02T03:04:05Z,x\n" +
+		"Ragged row,only,three\n" +
+		"Bad date,,,,yesterday,\n" +
+		" Trimmed ,,,,,\n"
+
+	rows, err := parseCSVImport(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("rows = %v, want 4", len(rows))
+	}
+
+	first := rows[0]
+	if first.err != nil {
+		t.Fatalf("row 1: %v", first.err)
+	}
+	want := Smer{
+		Situation: "Meeting",
+		Thoughts:  []string{"They hate me", "I will fail"},
+		Emotions:  []string{"anxiety"},
+		Reactions: []string{"silence"},
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(first.smer, want) {
+		t.Errorf("row 1 = %+v, want %+v", first.smer, want)
+	}
+
+	var parseErr *csv.ParseError
+	if !errors.As(rows[1].err, &parseErr) || parseErr.Err != csv.ErrFieldCount {
+		t.Errorf("row 2: err = %v, want %v", rows[1].err, csv.ErrFieldCount)
+	}
+	if rows[2].err == nil {
+		t.Error("row 3: an invalid createdAt was accepted")
+	}
+	if rows[3].err != nil || rows[3].smer.Situation != "Trimmed" || rows[3].smer.Thoughts == nil {
+		t.Errorf("row 4 = %+v, %v", rows[3].smer, rows[3].err)
+	}
+}
+
+func TestParseCSVImportHeader(t *testing.T) {
+	if _, err := parseCSVImport(strings.NewReader("thoughts,emotions\na,b\n")); err == nil {
+		t.Error("a header without situation was accepted")
+	}
+	if _, err := parseCSVImport(strings.NewReader("")); err == nil {
+		t.Error("an empty file was accepted")
+	}
+}
+
+func TestParseJSONImport(t *testing.T) {
+	input := `[
+		{"situation": "Meeting", "thoughts": ["a"], "emotions": ["anxiety"], "reactions": []},
+		{"situation": 42},
+		{"situation": "Rated", "thoughts": [], "emotionRatings": [{"name": "joy", "intensityBefore": 10}], "reactions": []}
+	]`
+
+	rows, err := parseJSONImport(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("rows = %v, want 3", len(rows))
+	}
+	if rows[0].err != nil || rows[0].smer.Situation != "Meeting" {
+		t.Errorf("row 1 = %+v, %v", rows[0].smer, rows[0].err)
+	}
+	if rows[1].err == nil {
+		t.Error("row 2: a number situation was accepted")
+	}
+	if rows[2].err != nil || len(rows[2].smer.EmotionRatings) != 1 {
+		t.Errorf("row 3 = %+v, %v", rows[2].smer, rows[2].err)
+	}
+
+	if _, err = parseJSONImport(strings.NewReader(`{"situation": "not a list"}`)); err == nil {
+		t.Error("an object instead of an array was accepted")
+	}
+}
+
+func TestValidateImport(t *testing.T) {
+	valid := Smer{Situation: "s", Thoughts: []string{}, Emotions: []string{}, Reactions: []string{}}
+	outOfRange := int16(101)
+
+	tests := []struct {
+		name  string
+		smer  func(smer Smer) Smer
+		valid bool
+	}{
+		{"valid", func(smer Smer) Smer { return smer }, true},
+		{"empty situation", func(smer Smer) Smer { smer.Situation = " "; return smer }, false},
+		{"no thoughts", func(smer Smer) Smer { smer.Thoughts = nil; return smer }, false},
+		{"no emotions", func(smer Smer) Smer { smer.Emotions = nil; return smer }, false},
+		{"no reactions", func(smer Smer) Smer { smer.Reactions = nil; return smer }, false},
+		{"only ratings", func(smer Smer) Smer {
+			smer.Emotions = nil
+			smer.EmotionRatings = []Emotion{{Name: "joy"}}
+			return smer
+		}, true},
+		{"rating out of range", func(smer Smer) Smer {
+			smer.EmotionRatings = []Emotion{{Name: "joy", IntensityBefore: &outOfRange}}
+			return smer
+		}, false},
+		{"future", func(smer Smer) Smer { smer.CreatedAt = time.Now().Add(time.Hour); return smer }, false},
+		{"unknown thought", func(smer Smer) Smer {
+			smer.ThoughtDistortions = []ThoughtDistortions{{Thought: 0, Distortions: []string{"labeling"}}}
+			return smer
+		}, false},
+	}
+
+	for _, test := range tests {
+		errs := validateImport(test.smer(valid))
+		if (len(errs) == 0) != test.valid {
+			t.Errorf("%v: errors = %v, want valid %v", test.name, errs, test.valid)
+		}
+	}
+}
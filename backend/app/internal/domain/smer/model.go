@@ -1,6 +1,12 @@
 package smer
 
-import "time"
+import (
+	db "backend/pkg/client/postgresql/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Smer struct {
 	Id        uint16    `json:"id" sql:"id"`
@@ -11,6 +17,46 @@ type Smer struct {
 	Reactions []string  `json:"reactions" sql:"reactions"`
 	CreatedAt time.Time `json:"createdAt" sql:"created_at"`
 	UpdatedAt time.Time `json:"updatedAt" sql:"updated_at"`
+	// DeletedAt is set while the smer is in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" sql:"deleted_at"`
+
+	CommentsCount uint64 `json:"commentsCount"`
+
+	// Tags are returned on read, TagIds are assigned on write (nil keeps the current tags).
+	Tags   []SmerTag `json:"tags,omitempty"`
+	TagIds []uint16  `json:"tagIds,omitempty"`
+
+	EmotionRatings     []Emotion            `json:"emotionRatings,omitempty"`
+	ThoughtDistortions []ThoughtDistortions `json:"thoughtDistortions,omitempty"`
+	Reframes           []Reframe            `json:"reframes,omitempty"`
+}
+
+// sortValues returns the smer's values of the sort columns, cursors are built from them.
+func (smer Smer) sortValues(sorts []*db.Sort) []any {
+	values := make([]any, 0, len(sorts))
+	for _, sort := range sorts {
+		switch strings.TrimPrefix(sort.Column(), table+".") {
+		case "id":
+			values = append(values, smer.Id)
+		case "created_at":
+			values = append(values, smer.CreatedAt)
+		case "updated_at":
+			values = append(values, smer.UpdatedAt)
+		case "deleted_at":
+			if smer.DeletedAt != nil {
+				values = append(values, *smer.DeletedAt)
+			}
+		case "situation":
+			values = append(values, smer.Situation)
+		}
+	}
+	return values
+}
+
+type SmerTag struct {
+	Id    uint16 `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
 }
 
 type NewSmerDto struct {
@@ -19,3 +65,240 @@ type NewSmerDto struct {
 	Emotions  []string `json:"emotions" sql:"emotions"`
 	Reactions []string `json:"reactions" sql:"reactions"`
 }
+
+// Emotion is an emotion rated 0-100 at the time of the situation
+// and once again after reframing.
+type Emotion struct {
+	Name            string `json:"name" sql:"name"`
+	IntensityBefore *int16 `json:"intensityBefore" sql:"intensity_before"`
+	IntensityAfter  *int16 `json:"intensityAfter" sql:"intensity_after"`
+}
+
+const (
+	MinIntensity = 0
+	MaxIntensity = 100
+)
+
+func (e Emotion) Validate() error {
+	if e.Name == "" {
+		return errors.New("emotion name is empty")
+	}
+	for _, intensity := range []*int16{e.IntensityBefore, e.IntensityAfter} {
+		if intensity != nil && (*intensity < MinIntensity || *intensity > MaxIntensity) {
+			return fmt.Errorf("emotion '%v': intensity must be between %d and %d", e.Name, MinIntensity, MaxIntensity)
+		}
+	}
+	return nil
+}
+
+// ThoughtDistortions tags the thought at Thoughts[Thought]
+// with cognitive distortion codes from the distortions catalog.
+type ThoughtDistortions struct {
+	Thought     int      `json:"thought" sql:"thought_index"`
+	Distortions []string `json:"distortions"`
+}
+
+// changedThoughts returns the indexes whose thought is not the same after the
+// update: edited, moved or removed. Tags of those indexes point at another thought.
+func changedThoughts(before, after []string) []int {
+	changed := make([]int, 0)
+	for i := range before {
+		if i >= len(after) || before[i] != after[i] {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// Reframe is the rational response to the thought at Thoughts[Thought].
+type Reframe struct {
+	Thought            int       `json:"thought" sql:"thought_index"`
+	EvidenceFor        []string  `json:"evidenceFor" sql:"evidence_for"`
+	EvidenceAgainst    []string  `json:"evidenceAgainst" sql:"evidence_against"`
+	AlternativeThought string    `json:"alternativeThought" sql:"alternative_thought"`
+	UpdatedAt          time.Time `json:"updatedAt" sql:"updated_at"`
+}
+
+// ReframePatchDto updates a single reframe, omitted fields are left untouched.
+type ReframePatchDto struct {
+	EvidenceFor        *[]string `json:"evidenceFor"`
+	EvidenceAgainst    *[]string `json:"evidenceAgainst"`
+	AlternativeThought *string   `json:"alternativeThought"`
+}
+
+func (smer Smer) Validate() error {
+	for _, emotion := range smer.EmotionRatings {
+		if err := emotion.Validate(); err != nil {
+			return err
+		}
+	}
+	tagged := make(map[int]bool, len(smer.ThoughtDistortions))
+	for _, tags := range smer.ThoughtDistortions {
+		if tags.Thought < 0 || tags.Thought >= len(smer.Thoughts) {
+			return fmt.Errorf("distortions refer to unknown thought %d", tags.Thought)
+		}
+		if tagged[tags.Thought] {
+			return fmt.Errorf("distortions of thought %d are listed twice", tags.Thought)
+		}
+		tagged[tags.Thought] = true
+	}
+	reframed := make(map[int]bool, len(smer.Reframes))
+	for _, reframe := range smer.Reframes {
+		if reframe.Thought < 0 || reframe.Thought >= len(smer.Thoughts) {
+			return fmt.Errorf("reframe refers to unknown thought %d", reframe.Thought)
+		}
+		if reframed[reframe.Thought] {
+			return fmt.Errorf("thought %d is reframed twice", reframe.Thought)
+		}
+		reframed[reframe.Thought] = true
+	}
+	return nil
+}
+
+// syncEmotions keeps Emotions and EmotionRatings in step, so clients
+// that only send the plain names keep working.
+func (smer *Smer) syncEmotions() {
+	if smer.EmotionRatings == nil {
+		smer.EmotionRatings = make([]Emotion, 0, len(smer.Emotions))
+		for _, name := range smer.Emotions {
+			smer.EmotionRatings = append(smer.EmotionRatings, Emotion{Name: name})
+		}
+		return
+	}
+
+	smer.Emotions = make([]string, 0, len(smer.EmotionRatings))
+	for _, emotion := range smer.EmotionRatings {
+		smer.Emotions = append(smer.Emotions, emotion.Name)
+	}
+}
+
+type SearchResult struct {
+	Smer
+	Rank float32 `json:"rank"`
+	// Headline is HTML: the snippet is escaped, the matches are in <mark>.
+	Headline string `json:"headline"`
+}
+
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+type Frequency struct {
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+}
+
+// StatsBucket aggregates smers created within [Start, Start + bucket).
+type StatsBucket struct {
+	Start                  time.Time   `json:"start"`
+	Count                  uint64      `json:"count"`
+	TopEmotions            []Frequency `json:"topEmotions"`
+	TopReactions           []Frequency `json:"topReactions"`
+	AverageIntensityBefore *float64    `json:"averageIntensityBefore"`
+	AverageIntensityAfter  *float64    `json:"averageIntensityAfter"`
+}
+
+type StatsParams struct {
+	From     time.Time
+	To       time.Time
+	Bucket   string
+	Location *time.Location
+	Top      uint64
+}
+
+// Comment is left on a smer, or on the thought at Thoughts[*Thought] when set.
+// Deleted comments keep their place in a thread but lose the body.
+type Comment struct {
+	Id        uint16     `json:"id" sql:"id"`
+	SmerId    uint16     `json:"smerId" sql:"smer_id"`
+	Thought   *int       `json:"thought" sql:"thought_index"`
+	ParentId  *uint16    `json:"parentId" sql:"parent_id"`
+	AuthorId  uint16     `json:"authorId" sql:"author_id"`
+	Author    string     `json:"author"`
+	Body      string     `json:"body" sql:"body"`
+	Edits     uint64     `json:"edits"`
+	CreatedAt time.Time  `json:"createdAt" sql:"created_at"`
+	UpdatedAt time.Time  `json:"updatedAt" sql:"updated_at"`
+	DeletedAt *time.Time `json:"deletedAt" sql:"deleted_at"`
+}
+
+type CommentEdit struct {
+	Body      string    `json:"body" sql:"body"`
+	CreatedAt time.Time `json:"createdAt" sql:"created_at"`
+}
+
+// ArchivedComment is a comment as it goes into the account archive, deleted
+// ones keep their body and every comment comes with its previous bodies.
+type ArchivedComment struct {
+	Comment
+	History []CommentEdit `json:"history"`
+}
+
+// ArchivedSmer is everything stored about a smer, trashed ones included,
+// as it goes into the account archive.
+type ArchivedSmer struct {
+	Smer
+	Comments  []ArchivedComment `json:"comments"`
+	Revisions []Revision        `json:"revisions"`
+}
+
+type NewCommentDto struct {
+	Body     string  `json:"body"`
+	Thought  *int    `json:"thought"`
+	ParentId *uint16 `json:"parentId"`
+}
+
+type UpdateCommentDto struct {
+	Body string `json:"body"`
+}
+
+const (
+	ImportStatusCreated  = "created"
+	ImportStatusValid    = "valid"
+	ImportStatusRejected = "rejected"
+)
+
+// ImportRow reports the outcome for a single imported row, rows are numbered from 1.
+type ImportRow struct {
+	Row    int      `json:"row"`
+	Status string   `json:"status"`
+	Id     *uint16  `json:"id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun  bool `json:"dryRun"`
+	Created int  `json:"created"`
+	// Valid counts the rows a dry run would have created.
+	Valid    int         `json:"valid"`
+	Rejected int         `json:"rejected"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+// Revision is a snapshot of a smer after a write, revisions are numbered from 1.
+type Revision struct {
+	Revision  uint32    `json:"revision" sql:"revision"`
+	Situation string    `json:"situation" sql:"situation"`
+	Thoughts  []string  `json:"thoughts" sql:"thoughts"`
+	Emotions  []string  `json:"emotions" sql:"emotions"`
+	Reactions []string  `json:"reactions" sql:"reactions"`
+	CreatedAt time.Time `json:"createdAt" sql:"created_at"`
+}
+
+// FieldDiff is a change of a single field. From and To are the whole values,
+// for list fields Added and Removed hold the items that differ.
+type FieldDiff struct {
+	Field   string      `json:"field"`
+	From    interface{} `json:"from"`
+	To      interface{} `json:"to"`
+	Added   []string    `json:"added,omitempty"`
+	Removed []string    `json:"removed,omitempty"`
+}
+
+type RevisionDiff struct {
+	From    uint32      `json:"from"`
+	To      uint32      `json:"to"`
+	Changes []FieldDiff `json:"changes"`
+}
@@ -0,0 +1,29 @@
+package smer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedThoughts(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []string
+		after  []string
+		want   []int
+	}{
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, []int{}},
+		{"appended", []string{"a"}, []string{"a", "b"}, []int{}},
+		{"edited", []string{"a", "b"}, []string{"a", "c"}, []int{1}},
+		{"reordered", []string{"a", "b", "c"}, []string{"b", "a", "c"}, []int{0, 1}},
+		{"removed last", []string{"a", "b"}, []string{"a"}, []int{1}},
+		{"removed first", []string{"a", "b"}, []string{"b"}, []int{0, 1}},
+		{"cleared", []string{"a"}, nil, []int{0}},
+	}
+
+	for _, test := range tests {
+		if got := changedThoughts(test.before, test.after); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: changedThoughts = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package smer
+
+import (
+	"backend/pkg/logging"
+	"context"
+	"time"
+)
+
+// Purger removes trashed smers once their retention is over.
+type Purger struct {
+	logger    *logging.Logger
+	storage   *Storage
+	retention time.Duration
+	interval  time.Duration
+	ctx       context.Context
+}
+
+func NewSmerPurger(ctx context.Context, storage *Storage, logger *logging.Logger, retention time.Duration, interval time.Duration) *Purger {
+	return &Purger{
+		logger:    logger,
+		storage:   storage,
+		retention: retention,
+		interval:  interval,
+		ctx:       ctx,
+	}
+}
+
+// Run blocks until the context is done.
+func (p *Purger) Run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := p.storage.Purge(p.retention); err == nil && purged > 0 {
+			p.logger.Infof("%d smers purged from the trash", purged)
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
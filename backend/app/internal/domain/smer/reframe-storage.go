@@ -0,0 +1,187 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	reframesTable = "smer_reframes"
+)
+
+// smerReframes is a JSON array of the selected smer's reframes.
+var smerReframes = fmt.Sprintf(
+	"(SELECT COALESCE(json_agg(json_build_object('thought', r.thought_index, 'evidenceFor', r.evidence_for, "+
+		"'evidenceAgainst', r.evidence_against, 'alternativeThought', r.alternative_thought, 'updatedAt', r.updated_at) "+
+		"ORDER BY r.thought_index), '[]') "+
+		"FROM %[1]v.%[2]v r WHERE r.smer_id = %[3]v.id) AS reframes",
+	scheme, reframesTable, table,
+)
+
+func (s *Storage) reframes(smerId uint16) ([]Reframe, error) {
+	query := s.queryBuilder.Select("thought_index", "evidence_for", "evidence_against", "alternative_thought", "updated_at").
+		From(scheme + "." + reframesTable).
+		Where(sq.Eq{"smer_id": smerId}).
+		OrderBy("thought_index")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, reframesTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Reframe, 0)
+
+	for rows.Next() {
+		p := Reframe{}
+		if err = rows.Scan(&p.Thought, &p.EvidenceFor, &p.EvidenceAgainst, &p.AlternativeThought, &p.UpdatedAt); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
+
+// replaceReframes stores reframes of the whole smer. When reframes is nil (a client that
+// doesn't know about reframing) the existing ones are kept, except for the changed
+// thoughts (see changedThoughts): they would reframe another thought otherwise.
+func (s *Storage) replaceReframes(tx pgx.Tx, smerId uint16, changed []int, reframes []Reframe) error {
+	removeQuery := s.queryBuilder.Delete(scheme + "." + reframesTable).Where(sq.Eq{"smer_id": smerId})
+	if reframes == nil {
+		removeQuery = removeQuery.Where(sq.Eq{"thought_index": changed})
+	}
+
+	sql, args, err := removeQuery.ToSql()
+	logger := s.queryLogger(sql, reframesTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Deleting smer reframes")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	if len(reframes) == 0 {
+		return nil
+	}
+
+	insertQuery := s.queryBuilder.Insert(scheme+"."+reframesTable).
+		Columns("smer_id", "thought_index", "evidence_for", "evidence_against", "alternative_thought")
+	for _, reframe := range reframes {
+		insertQuery = insertQuery.Values(
+			smerId, reframe.Thought, nonNil(reframe.EvidenceFor), nonNil(reframe.EvidenceAgainst), reframe.AlternativeThought,
+		)
+	}
+
+	sql, args, err = insertQuery.ToSql()
+	logger = s.queryLogger(sql, reframesTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Creating smer reframes")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// PatchReframe creates or partially updates the reframe of a single thought
+// and records a revision of the smer.
+// Returns pgx.ErrNoRows when the smer or the thought doesn't exist.
+func (s *Storage) PatchReframe(userId uint16, id uint16, thought int, patch ReframePatchDto) (*Reframe, error) {
+	source := sq.Select().
+		Column("id").
+		Column("?::smallint", thought).
+		Column("COALESCE(?::text[], '{}')", patch.EvidenceFor).
+		Column("COALESCE(?::text[], '{}')", patch.EvidenceAgainst).
+		Column("COALESCE(?::text, '')", patch.AlternativeThought).
+		From(scheme+"."+table).
+		Where(sq.Eq{"id": id}).
+		Where(owned(userId)).
+		Where("? < cardinality(thoughts)", thought)
+
+	query := s.queryBuilder.Insert(scheme+"."+reframesTable).
+		Columns("smer_id", "thought_index", "evidence_for", "evidence_against", "alternative_thought").
+		Select(source).
+		Suffix(fmt.Sprintf(
+			"ON CONFLICT (smer_id, thought_index) DO UPDATE SET "+
+				"evidence_for = COALESCE(?::text[], %[1]v.evidence_for), "+
+				"evidence_against = COALESCE(?::text[], %[1]v.evidence_against), "+
+				"alternative_thought = COALESCE(?::text, %[1]v.alternative_thought) "+
+				"RETURNING thought_index, evidence_for, evidence_against, alternative_thought, updated_at",
+			reframesTable,
+		), patch.EvidenceFor, patch.EvidenceAgainst, patch.AlternativeThought)
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, reframesTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	var reframe Reframe
+
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		if _, err := s.lockThoughts(tx, userId, id); err != nil {
+			return err
+		}
+
+		logger.Trace("Patching smer reframe")
+		if err := tx.QueryRow(s.ctx, sql, args...).Scan(
+			&reframe.Thought, &reframe.EvidenceFor, &reframe.EvidenceAgainst, &reframe.AlternativeThought, &reframe.UpdatedAt,
+		); err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				err = db.ErrScan(err)
+				logger.Error(err)
+			}
+			return err
+		}
+
+		if err := s.touch(tx, id); err != nil {
+			return err
+		}
+
+		return s.addRevision(tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &reframe, nil
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
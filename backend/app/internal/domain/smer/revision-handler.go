@@ -0,0 +1,98 @@
+package smer
+
+import (
+	"backend/pkg/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	revisionsURL       = "/api/smers/:smerId/revisions"
+	revisionsDiffURL   = "/api/smers/:smerId/revisions/diff"
+	revisionRestoreURL = "/api/smers/:smerId/revisions/:revision/restore"
+)
+
+func (h *Handler) GetRevisions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	revisions, err := h.storage.Revisions(userId, uint16(smerId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, revisions)
+}
+
+// DiffRevisions compares revisions ?from= and ?to=, by default the latest one with its predecessor.
+func (h *Handler) DiffRevisions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var from, to uint64
+	queryValues := r.URL.Query()
+	if value := queryValues.Get("from"); value != "" {
+		if from, err = strconv.ParseUint(value, 10, 32); err != nil || from == 0 {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid revision: '"+value+"'")
+			return
+		}
+	}
+	if value := queryValues.Get("to"); value != "" {
+		if to, err = strconv.ParseUint(value, 10, 32); err != nil || to == 0 {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid revision: '"+value+"'")
+			return
+		}
+	}
+
+	userId := r.Context().Value("userId").(uint16)
+	diff, err := h.storage.DiffRevisions(userId, uint16(smerId), uint32(from), uint32(to))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer or revision not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, diff)
+}
+
+func (h *Handler) RestoreRevision(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	smerId, err := strconv.ParseUint(ps.ByName("smerId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	revision, err := strconv.ParseUint(ps.ByName("revision"), 10, 32)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	err = h.storage.RestoreRevision(userId, uint16(smerId), uint32(revision))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Smer or revision not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, smerId)
+}
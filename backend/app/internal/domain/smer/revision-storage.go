@@ -0,0 +1,199 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const revisionsTable = "smer_revisions"
+
+// smerRevisions is a JSON array of the selected smer's revisions, the latest first.
+var smerRevisions = fmt.Sprintf(
+	"(SELECT COALESCE(json_agg(json_build_object('revision', r.revision, 'situation', r.situation, 'thoughts', r.thoughts, "+
+		"'emotions', r.emotions, 'reactions', r.reactions, 'createdAt', r.created_at) ORDER BY r.revision DESC), '[]') "+
+		"FROM %[1]v.%[2]v r WHERE r.smer_id = %[3]v.id) AS revisions",
+	scheme, revisionsTable, table,
+)
+
+// addRevision stores the current state of the smer as its next revision.
+// It runs after the smer row is written in the same transaction, the row
+// lock keeps concurrent updates from taking the same number.
+func (s *Storage) addRevision(tx pgx.Tx, smerId uint16) error {
+	next := sq.Select("COALESCE(MAX(revision), 0) + 1").
+		From(scheme + "." + revisionsTable).
+		Where(sq.Eq{"smer_id": smerId})
+
+	snapshot := sq.Select().
+		Column("id").
+		Column(sq.Alias(next, "revision")).
+		Columns("situation", "thoughts", "emotions", "reactions", "updated_at").
+		From(scheme + "." + table).
+		Where(sq.Eq{"id": smerId})
+
+	query := s.queryBuilder.Insert(scheme+"."+revisionsTable).
+		Columns("smer_id", "revision", "situation", "thoughts", "emotions", "reactions", "created_at").
+		Select(snapshot)
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, revisionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Adding revision")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// touch moves the smer's updated_at when only its nested data changes,
+// so the revision recorded next gets the time of the change.
+func (s *Storage) touch(tx pgx.Tx, smerId uint16) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": smerId})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Touching smer")
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// Revisions lists the history of the user's smer, the latest revision first.
+// It returns pgx.ErrNoRows when the smer is not found.
+func (s *Storage) Revisions(userId uint16, smerId uint16) ([]Revision, error) {
+	query := s.queryBuilder.Select("revision", "situation", "thoughts", "emotions", "reactions", "created_at").
+		From(scheme + "." + revisionsTable).
+		Where(sq.Eq{"smer_id": smerId}).
+		Where(revisedSmerOwned(userId)).
+		OrderBy("revision DESC")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, revisionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Revision, 0)
+	for rows.Next() {
+		var revision Revision
+		if err = rows.Scan(
+			&revision.Revision, &revision.Situation, &revision.Thoughts, &revision.Emotions, &revision.Reactions, &revision.CreatedAt,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+		list = append(list, revision)
+	}
+
+	if len(list) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return list, nil
+}
+
+// DiffRevisions compares two revisions of the user's smer.
+// A zero to means the latest revision, a zero from the one before to.
+func (s *Storage) DiffRevisions(userId uint16, smerId uint16, from uint32, to uint32) (*RevisionDiff, error) {
+	revisions, err := s.Revisions(userId, smerId)
+	if err != nil {
+		return nil, err
+	}
+
+	if to == 0 {
+		to = revisions[0].Revision
+	}
+	if from == 0 && to > 1 {
+		from = to - 1
+	}
+
+	var fromRevision, toRevision *Revision
+	for i := range revisions {
+		switch revisions[i].Revision {
+		case from:
+			fromRevision = &revisions[i]
+		case to:
+			toRevision = &revisions[i]
+		}
+	}
+	if from == to && toRevision != nil {
+		fromRevision = toRevision
+	}
+	if fromRevision == nil || toRevision == nil {
+		return nil, pgx.ErrNoRows
+	}
+
+	diff := diffRevisions(*fromRevision, *toRevision)
+	return &diff, nil
+}
+
+// RestoreRevision writes the revision back to the smer, which makes a new revision.
+// Ratings, distortions and reframes of thoughts that are still there are kept.
+func (s *Storage) RestoreRevision(userId uint16, smerId uint16, revision uint32) error {
+	var smer Smer
+
+	query := s.queryBuilder.Select("situation", "thoughts", "emotions", "reactions").
+		From(scheme + "." + revisionsTable).
+		Where(sq.Eq{"smer_id": smerId, "revision": revision}).
+		Where(revisedSmerOwned(userId))
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, revisionsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(
+		&smer.Situation, &smer.Thoughts, &smer.Emotions, &smer.Reactions,
+	); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			err = db.ErrScan(err)
+			logger.Error(err)
+		}
+		return err
+	}
+
+	return s.Update(userId, smerId, smer)
+}
+
+// revisedSmerOwned limits revisions to the ones of the user's smers.
+func revisedSmerOwned(userId uint16) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("EXISTS (SELECT 1 FROM %[1]v.%[2]v WHERE %[2]v.id = %[3]v.smer_id AND ?)", scheme, table, revisionsTable), owned(userId))
+}
@@ -0,0 +1,72 @@
+package smer
+
+// diffRevisions compares the fields of two revisions, unchanged fields are left out.
+func diffRevisions(from, to Revision) RevisionDiff {
+	diff := RevisionDiff{
+		From:    from.Revision,
+		To:      to.Revision,
+		Changes: make([]FieldDiff, 0),
+	}
+
+	if from.Situation != to.Situation {
+		diff.Changes = append(diff.Changes, FieldDiff{
+			Field: "situation",
+			From:  from.Situation,
+			To:    to.Situation,
+		})
+	}
+
+	lists := []struct {
+		field    string
+		from, to []string
+	}{
+		{"thoughts", from.Thoughts, to.Thoughts},
+		{"emotions", from.Emotions, to.Emotions},
+		{"reactions", from.Reactions, to.Reactions},
+	}
+	for _, list := range lists {
+		if equalLists(list.from, list.to) {
+			continue
+		}
+		diff.Changes = append(diff.Changes, FieldDiff{
+			Field:   list.field,
+			From:    list.from,
+			To:      list.to,
+			Added:   subtractList(list.to, list.from),
+			Removed: subtractList(list.from, list.to),
+		})
+	}
+
+	return diff
+}
+
+func equalLists(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// subtractList returns the items of a missing from b, repeated items are counted.
+// A reordered list has nothing added or removed.
+func subtractList(a, b []string) []string {
+	counts := make(map[string]int, len(b))
+	for _, item := range b {
+		counts[item]++
+	}
+
+	result := make([]string, 0)
+	for _, item := range a {
+		if counts[item] > 0 {
+			counts[item]--
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package smer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	base := Revision{
+		Revision:  1,
+		Situation: "meeting",
+		Thoughts:  []string{"a", "b"},
+		Emotions:  []string{"fear"},
+		Reactions: []string{"silence"},
+	}
+
+	tests := []struct {
+		name string
+		edit func(r *Revision)
+		want []FieldDiff
+	}{
+		{"unchanged", func(r *Revision) {}, []FieldDiff{}},
+		{
+			"situation",
+			func(r *Revision) { r.Situation = "call" },
+			[]FieldDiff{{Field: "situation", From: "meeting", To: "call"}},
+		},
+		{
+			"thought added",
+			func(r *Revision) { r.Thoughts = []string{"a", "b", "c"} },
+			[]FieldDiff{{
+				Field: "thoughts", From: []string{"a", "b"}, To: []string{"a", "b", "c"},
+				Added: []string{"c"}, Removed: []string{},
+			}},
+		},
+		{
+			"reordered",
+			func(r *Revision) { r.Thoughts = []string{"b", "a"} },
+			[]FieldDiff{{
+				Field: "thoughts", From: []string{"a", "b"}, To: []string{"b", "a"},
+				Added: []string{}, Removed: []string{},
+			}},
+		},
+		{
+			"repeated item added",
+			func(r *Revision) { r.Emotions = []string{"fear", "fear"} },
+			[]FieldDiff{{
+				Field: "emotions", From: []string{"fear"}, To: []string{"fear", "fear"},
+				Added: []string{"fear"}, Removed: []string{},
+			}},
+		},
+		{
+			"several fields",
+			func(r *Revision) {
+				r.Situation = "call"
+				r.Reactions = nil
+			},
+			[]FieldDiff{
+				{Field: "situation", From: "meeting", To: "call"},
+				{
+					Field: "reactions", From: []string{"silence"}, To: []string(nil),
+					Added: []string{}, Removed: []string{"silence"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		to := base
+		to.Revision = 2
+		test.edit(&to)
+
+		diff := diffRevisions(base, to)
+		if diff.From != 1 || diff.To != 2 {
+			t.Errorf("%v: diffRevisions compares %v and %v, want 1 and 2", test.name, diff.From, diff.To)
+		}
+		if !reflect.DeepEqual(diff.Changes, test.want) {
+			t.Errorf("%v: diffRevisions = %+v, want %+v", test.name, diff.Changes, test.want)
+		}
+	}
+}
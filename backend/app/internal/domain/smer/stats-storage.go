@@ -0,0 +1,80 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"fmt"
+)
+
+// Stats computes per-bucket aggregates in a single query, so charts don't
+// need to download every smer. Buckets without entries are omitted.
+// Buckets are cut in the local time, their start is converted back to an instant.
+func (s *Storage) Stats(userId uint16, params StatsParams) ([]StatsBucket, error) {
+	sql := fmt.Sprintf(`
+WITH entries AS (
+    SELECT id, date_trunc($2, created_at AT TIME ZONE $3) AT TIME ZONE $3 AS bucket, emotions, reactions
+    FROM %[1]v.%[2]v
+    WHERE user_id = $1 AND deleted_at IS NULL AND created_at >= $4 AND created_at < $5
+),
+counts AS (
+    SELECT bucket, COUNT(*) AS total FROM entries GROUP BY bucket
+),
+emotions AS (
+    SELECT bucket, name, COUNT(*) AS total,
+           row_number() OVER (PARTITION BY bucket ORDER BY COUNT(*) DESC, name) AS rank
+    FROM entries, unnest(entries.emotions) AS name
+    GROUP BY bucket, name
+),
+reactions AS (
+    SELECT bucket, name, COUNT(*) AS total,
+           row_number() OVER (PARTITION BY bucket ORDER BY COUNT(*) DESC, name) AS rank
+    FROM entries, unnest(entries.reactions) AS name
+    GROUP BY bucket, name
+),
+intensities AS (
+    SELECT entries.bucket,
+           AVG(rated.intensity_before)::float8 AS before,
+           AVG(rated.intensity_after)::float8  AS after
+    FROM entries JOIN %[1]v.%[3]v rated ON rated.smer_id = entries.id
+    GROUP BY entries.bucket
+)
+SELECT counts.bucket,
+       counts.total,
+       COALESCE((SELECT json_agg(json_build_object('name', name, 'count', total) ORDER BY rank)
+                 FROM emotions WHERE emotions.bucket = counts.bucket AND rank <= $6), '[]'),
+       COALESCE((SELECT json_agg(json_build_object('name', name, 'count', total) ORDER BY rank)
+                 FROM reactions WHERE reactions.bucket = counts.bucket AND rank <= $6), '[]'),
+       intensities.before,
+       intensities.after
+FROM counts
+         LEFT JOIN intensities ON intensities.bucket = counts.bucket
+ORDER BY counts.bucket`, scheme, table, emotionsTable)
+
+	args := []interface{}{userId, params.Bucket, params.Location.String(), params.From, params.To, params.Top}
+	logger := s.queryLogger(sql, table, args)
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]StatsBucket, 0)
+
+	for rows.Next() {
+		p := StatsBucket{}
+		if err = rows.Scan(
+			&p.Start, &p.Count, &p.TopEmotions, &p.TopReactions, &p.AverageIntensityBefore, &p.AverageIntensityAfter,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
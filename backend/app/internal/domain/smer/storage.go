@@ -6,9 +6,12 @@ import (
 	"backend/pkg/logging"
 	"backend/pkg/utils"
 	"context"
-	"fmt"
+	"errors"
 	sq "github.com/Masterminds/squirrel"
-	"math"
+	"github.com/jackc/pgx/v4"
+	"html"
+	"strings"
+	"time"
 )
 
 type Storage struct {
@@ -41,6 +44,27 @@ func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Lo
 }
 
 func (s *Storage) All(userId uint16, filters []*db.Filter, pagination *db.Pagination, sorts ...*db.Sort) ([]Smer, *utils.Meta, error) {
+	return s.all(owned(userId), filters, pagination, order(sorts, "created_at"))
+}
+
+// Trash lists the user's trashed smers, by default the latest deleted first.
+func (s *Storage) Trash(userId uint16, filters []*db.Filter, pagination *db.Pagination, sorts ...*db.Sort) ([]Smer, *utils.Meta, error) {
+	return s.all(trashed(userId), filters, pagination, order(sorts, "deleted_at"))
+}
+
+// AllShared lists the client's smers on behalf of the therapist.
+func (s *Storage) AllShared(therapistId uint16, clientId uint16, filters []*db.Filter, pagination *db.Pagination, sorts ...*db.Sort) ([]Smer, *utils.Meta, error) {
+	return s.all(shared(therapistId, clientId), filters, pagination, order(sorts, "created_at"))
+}
+
+// order completes the requested sorts: without them the newest by defaultColumn
+// go first, id is always the last column so the order (and cursors) are stable.
+func order(sorts []*db.Sort, defaultColumn string) []*db.Sort {
+	return db.WithTiebreaker(sorts, db.NewSort(table+"."+defaultColumn, "DESC"), table+".id")
+}
+
+// all lists smers in page or cursor mode, cursors are keyed on the order columns.
+func (s *Storage) all(access sq.Sqlizer, filters []*db.Filter, pagination *db.Pagination, order []*db.Sort) ([]Smer, *utils.Meta, error) {
 	query := s.queryBuilder.Select(
 		"id",
 		"user_id",
@@ -50,15 +74,32 @@ func (s *Storage) All(userId uint16, filters []*db.Filter, pagination *db.Pagina
 		"reactions",
 		"created_at",
 		"updated_at",
-	).From(scheme + "." + table).Where(sq.Eq{"user_id": userId})
+		"deleted_at",
+		commentsCount,
+		smerTags,
+	).From(scheme + "." + table).Where(access)
+	countQuery := s.queryBuilder.Select("COUNT(*)").From(scheme + "." + table).Where(access)
 
 	for _, filter := range filters {
 		s.logger.Trace(filter)
 		query = filter.UseSelectBuilder(query)
+		countQuery = filter.UseSelectBuilder(countQuery)
 	}
 
-	if pagination != nil {
-		query = pagination.UseSelectBuilder(query)
+	keyset := pagination != nil && pagination.Keyset
+
+	if keyset {
+		var err error
+		if query, err = pagination.UseKeysetBuilder(query, order); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		for _, sort := range order {
+			query = sort.UseSelectBuilder(query)
+		}
+		if pagination != nil {
+			query = pagination.UseSelectBuilder(query)
+		}
 	}
 
 	sql, args, err := query.ToSql()
@@ -85,7 +126,7 @@ func (s *Storage) All(userId uint16, filters []*db.Filter, pagination *db.Pagina
 	for rows.Next() {
 		p := Smer{}
 		if err = rows.Scan(
-			&p.Id, &p.UserId, &p.Situation, &p.Thoughts, &p.Emotions, &p.Reactions, &p.CreatedAt, &p.UpdatedAt,
+			&p.Id, &p.UserId, &p.Situation, &p.Thoughts, &p.Emotions, &p.Reactions, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CommentsCount, &p.Tags,
 		); err != nil {
 			err = db.ErrScan(err)
 			logger.Error(err)
@@ -95,33 +136,91 @@ func (s *Storage) All(userId uint16, filters []*db.Filter, pagination *db.Pagina
 		list = append(list, p)
 	}
 
+	meta := &utils.Meta{}
+
+	if keyset {
+		fetched := len(list)
+		list = db.KeysetPage(*pagination, list)
+		if len(list) > 0 {
+			meta.Next, meta.Prev = pagination.Cursors(order, fetched, list[0].sortValues(order), list[len(list)-1].sortValues(order))
+		}
+	}
+
+	if pagination != nil && !pagination.Count {
+		return list, meta, nil
+	}
+
+	if err = s.count(countQuery, pagination, meta); err != nil {
+		return nil, nil, err
+	}
+
+	return list, meta, nil
+}
+
+// count fills the totals of the meta.
+func (s *Storage) count(countQuery sq.SelectBuilder, pagination *db.Pagination, meta *utils.Meta) error {
+	sql, args, err := countQuery.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
 	var count uint64
 
-	err = s.client.QueryRow(s.ctx, fmt.Sprintf("SELECT COUNT(*) FROM %v.%v", scheme, table)).Scan(&count)
+	err = s.client.QueryRow(s.ctx, sql, args...).Scan(&count)
 	if err != nil {
 		err = db.ErrDoQuery(err)
 		logger.Error(err)
-		return nil, nil, err
+		return err
 	}
-	meta := &utils.Meta{
-		TotalItems: count,
-		TotalPages: uint64(math.Ceil(float64(count) / float64(pagination.Limit))),
+
+	meta.TotalItems = &count
+	if pagination != nil {
+		pages := pagination.Pages(count)
+		meta.TotalPages = &pages
 	}
 
-	return list, meta, nil
+	return nil
 }
 
 func (s *Storage) Create(smer Smer, userId uint16) (uint16, error) {
 
 	lastInsertId := uint16(0)
 
-	query := s.queryBuilder.Insert(scheme+"."+table).Columns(
-		"user_id",
-		"situation",
-		"thoughts",
-		"emotions",
-		"reactions",
-	).Values(userId, smer.Situation, smer.Thoughts, smer.Emotions, smer.Reactions).Suffix("RETURNING id")
+	// creation time is only taken from the client on import
+	smer.CreatedAt = time.Time{}
+
+	err := s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		var err error
+		lastInsertId, err = s.create(tx, smer, userId)
+		return err
+	})
+
+	if err != nil {
+		return lastInsertId, err
+	}
+
+	return lastInsertId, nil
+}
+
+// create inserts the smer with its nested data. CreatedAt is kept when set,
+// so entries imported from elsewhere retain their original dates.
+func (s *Storage) create(tx pgx.Tx, smer Smer, userId uint16) (uint16, error) {
+
+	lastInsertId := uint16(0)
+
+	smer.syncEmotions()
+
+	columns := []string{"user_id", "situation", "thoughts", "emotions", "reactions"}
+	values := []interface{}{userId, smer.Situation, smer.Thoughts, smer.Emotions, smer.Reactions}
+	if !smer.CreatedAt.IsZero() {
+		columns = append(columns, "created_at")
+		values = append(values, smer.CreatedAt)
+	}
+
+	query := s.queryBuilder.Insert(scheme + "." + table).Columns(columns...).Values(values...).Suffix("RETURNING id")
 
 	sql, args, err := query.ToSql()
 	logger := s.queryLogger(sql, table, args)
@@ -131,17 +230,44 @@ func (s *Storage) Create(smer Smer, userId uint16) (uint16, error) {
 		return lastInsertId, err
 	}
 
-	err = s.client.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId)
-
-	if err != nil {
+	if err = tx.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId); err != nil {
 		logger.Error(err)
 		return lastInsertId, err
 	}
 
+	if err = s.replaceEmotions(tx, lastInsertId, smer.EmotionRatings); err != nil {
+		return lastInsertId, err
+	}
+
+	if err = s.replaceDistortions(tx, lastInsertId, nil, smer.ThoughtDistortions); err != nil {
+		return lastInsertId, err
+	}
+
+	if err = s.replaceReframes(tx, lastInsertId, nil, smer.Reframes); err != nil {
+		return lastInsertId, err
+	}
+
+	if err = s.replaceTags(tx, lastInsertId, userId, smer.TagIds); err != nil {
+		return lastInsertId, err
+	}
+
+	if err = s.addRevision(tx, lastInsertId); err != nil {
+		return lastInsertId, err
+	}
+
 	return lastInsertId, nil
 }
 
 func (s *Storage) GetById(userId uint16, id uint16) (*Smer, error) {
+	return s.getById(owned(userId), id)
+}
+
+// GetShared reads the client's smer on behalf of the therapist.
+func (s *Storage) GetShared(therapistId uint16, clientId uint16, id uint16) (*Smer, error) {
+	return s.getById(shared(therapistId, clientId), id)
+}
+
+func (s *Storage) getById(access sq.Sqlizer, id uint16) (*Smer, error) {
 
 	var smer Smer
 
@@ -154,7 +280,9 @@ func (s *Storage) GetById(userId uint16, id uint16) (*Smer, error) {
 		"reactions",
 		"created_at",
 		"updated_at",
-	).From(scheme + "." + table).Where(sq.Eq{"id": id}).Where(sq.Eq{"user_id": userId})
+		commentsCount,
+		smerTags,
+	).From(scheme + "." + table).Where(sq.Eq{"id": id}).Where(access)
 
 	sql, args, err := query.ToSql()
 	logger := s.queryLogger(sql, table, args)
@@ -167,23 +295,41 @@ func (s *Storage) GetById(userId uint16, id uint16) (*Smer, error) {
 	row := s.client.QueryRow(s.ctx, sql, args...)
 
 	if err = row.Scan(
-		&smer.Id, &smer.UserId, &smer.Situation, &smer.Thoughts, &smer.Emotions, &smer.Reactions, &smer.CreatedAt, &smer.UpdatedAt,
+		&smer.Id, &smer.UserId, &smer.Situation, &smer.Thoughts, &smer.Emotions, &smer.Reactions, &smer.CreatedAt, &smer.UpdatedAt, &smer.CommentsCount, &smer.Tags,
 	); err != nil {
 		err = db.ErrScan(err)
 		logger.Error(err)
 		return nil, err
 	}
 
+	smer.EmotionRatings, err = s.emotions(smer.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	smer.ThoughtDistortions, err = s.distortions(smer.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	smer.Reframes, err = s.reframes(smer.Id)
+	if err != nil {
+		return nil, err
+	}
+
 	return &smer, nil
 }
 
 func (s *Storage) Update(userId uint16, id uint16, smer Smer) error {
+	rated := smer.EmotionRatings != nil
+	smer.syncEmotions()
+
 	query := s.queryBuilder.Update(scheme+"."+table).
 		Set("situation", smer.Situation).
 		Set("thoughts", smer.Thoughts).
 		Set("emotions", smer.Emotions).
 		Set("reactions", smer.Reactions).
-		Where(sq.Eq{"id": id}).Where(sq.Eq{"user_id": userId})
+		Where(sq.Eq{"id": id}).Where(owned(userId))
 
 	sql, args, err := query.ToSql()
 	logger := s.queryLogger(sql, table, args)
@@ -193,22 +339,99 @@ func (s *Storage) Update(userId uint16, id uint16, smer Smer) error {
 		return err
 	}
 
-	logger.Trace("do query")
-	_, err = s.client.Exec(s.ctx, sql, args...)
+	if !rated {
+		if err = s.keepIntensities(id, smer.EmotionRatings); err != nil {
+			return err
+		}
+	}
+
+	return s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		before, err := s.lockThoughts(tx, userId, id)
+		if err != nil {
+			return err
+		}
+		changed := changedThoughts(before, smer.Thoughts)
+
+		logger.Trace("do query")
+		tag, err := tx.Exec(s.ctx, sql, args...)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
 
+		if err := s.replaceEmotions(tx, id, smer.EmotionRatings); err != nil {
+			return err
+		}
+
+		if err := s.replaceDistortions(tx, id, changed, smer.ThoughtDistortions); err != nil {
+			return err
+		}
+
+		if err := s.replaceReframes(tx, id, changed, smer.Reframes); err != nil {
+			return err
+		}
+
+		if err := s.replaceTags(tx, id, userId, smer.TagIds); err != nil {
+			return err
+		}
+
+		return s.addRevision(tx, id)
+	})
+}
+
+// lockThoughts reads the thoughts before an update and locks the smer till the end of it.
+// Returns pgx.ErrNoRows when there is no such smer outside the trash.
+func (s *Storage) lockThoughts(tx pgx.Tx, userId uint16, id uint16) ([]string, error) {
+	var thoughts []string
+
+	query := s.queryBuilder.Select("thoughts").
+		From(scheme + "." + table).
+		Where(sq.Eq{"id": id}).Where(owned(userId)).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
 	if err != nil {
+		err = db.ErrCreateQuery(err)
 		logger.Error(err)
-		return err
+		return nil, err
 	}
 
-	return nil
+	if err = tx.QueryRow(s.ctx, sql, args...).Scan(&thoughts); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			err = db.ErrScan(err)
+			logger.Error(err)
+		}
+		return nil, err
+	}
+
+	return thoughts, nil
 }
 
+// Delete moves the smer to the trash, the purger removes it for good after the retention.
+// Returns pgx.ErrNoRows when there is no such smer outside the trash.
 func (s *Storage) Delete(userId uint16, id uint16) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("deleted_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).Where(owned(userId))
 
-	query := s.queryBuilder.Delete("users").
-		Where(sq.Eq{"id": id}).Where(sq.Eq{"user_id": userId})
+	return s.setTrashed(query)
+}
+
+// Restore takes the smer back from the trash.
+// Returns pgx.ErrNoRows when there is no such smer in the trash.
+func (s *Storage) Restore(userId uint16, id uint16) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("deleted_at", nil).
+		Where(sq.Eq{"id": id}).Where(trashed(userId))
 
+	return s.setTrashed(query)
+}
+
+func (s *Storage) setTrashed(query sq.UpdateBuilder) error {
 	sql, args, err := query.ToSql()
 	logger := s.queryLogger(sql, table, args)
 	if err != nil {
@@ -218,12 +441,131 @@ func (s *Storage) Delete(userId uint16, id uint16) error {
 	}
 
 	logger.Trace("do query")
-	_, err = s.client.Exec(s.ctx, sql, args...)
-
+	tag, err := s.client.Exec(s.ctx, sql, args...)
 	if err != nil {
 		logger.Error(err)
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 
 	return nil
 }
+
+// Purge removes smers that stayed in the trash longer than the retention.
+// Everything that belongs to them goes by cascade.
+func (s *Storage) Purge(retention time.Duration) (int64, error) {
+	query := s.queryBuilder.Delete(scheme + "." + table).
+		Where(sq.Lt{"deleted_at": time.Now().Add(-retention)})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return 0, err
+	}
+
+	logger.Trace("Purging trash")
+	tag, err := s.client.Exec(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+const (
+	// searchConfig is the text search configuration used for smers.search_vector.
+	searchConfig = "russian"
+	// headlineStart and headlineStop mark the matches of ts_headline, they are
+	// private use characters that are stripped from the content beforehand.
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+	// searchHeadlineOptions control the highlighted snippet returned with search results.
+	searchHeadlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop +
+		", MaxFragments=3, MinWords=5, MaxWords=20, FragmentDelimiter=\" … \""
+)
+
+// headlineHTML escapes the snippet and wraps its matches in <mark>.
+func headlineHTML(headline string) string {
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").
+		Replace(html.EscapeString(headline))
+}
+
+func (s *Storage) Search(userId uint16, text string, pagination *db.Pagination) ([]SearchResult, *utils.Meta, error) {
+	tsQuery := "websearch_to_tsquery('" + searchConfig + "', ?)"
+	matches := sq.And{owned(userId), sq.Expr("search_vector @@ "+tsQuery, text)}
+
+	query := s.queryBuilder.Select(
+		"id",
+		"user_id",
+		"situation",
+		"thoughts",
+		"emotions",
+		"reactions",
+		"created_at",
+		"updated_at",
+		commentsCount,
+		smerTags,
+	).
+		Column(sq.Expr("ts_rank_cd(search_vector, "+tsQuery+") AS rank", text)).
+		Column(sq.Expr(
+			"ts_headline('"+searchConfig+"', translate(concat_ws(' … ', situation, array_to_string(thoughts, ' … '), "+
+				"array_to_string(emotions, ', '), array_to_string(reactions, ' … ')), ?, ''), "+tsQuery+", ?)",
+			headlineStart+headlineStop, text, searchHeadlineOptions,
+		)).
+		From(scheme+"."+table).
+		Where(matches).
+		OrderBy("rank DESC", "created_at DESC")
+
+	if pagination != nil {
+		query = pagination.UseSelectBuilder(query)
+	}
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]SearchResult, 0)
+
+	for rows.Next() {
+		p := SearchResult{}
+		if err = rows.Scan(
+			&p.Id, &p.UserId, &p.Situation, &p.Thoughts, &p.Emotions, &p.Reactions, &p.CreatedAt, &p.UpdatedAt, &p.CommentsCount, &p.Tags,
+			&p.Rank, &p.Headline,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, nil, err
+		}
+
+		p.Headline = headlineHTML(p.Headline)
+		list = append(list, p)
+	}
+
+	countQuery := s.queryBuilder.Select("COUNT(*)").From(scheme + "." + table).Where(matches)
+
+	meta := &utils.Meta{}
+	if err = s.count(countQuery, pagination, meta); err != nil {
+		return nil, nil, err
+	}
+
+	return list, meta, nil
+}
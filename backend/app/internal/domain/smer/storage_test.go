@@ -0,0 +1,21 @@
+package smer
+
+import "testing"
+
+func TestHeadlineHTML(t *testing.T) {
+	tests := []struct {
+		headline string
+		want     string
+	}{
+		{"plain text", "plain text"},
+		{"a " + headlineStart + "match" + headlineStop + " here", "a <mark>match</mark> here"},
+		{"<script>" + headlineStart + "alert" + headlineStop + "</script>", "&lt;script&gt;<mark>alert</mark>&lt;/script&gt;"},
+		{"<mark>fake</mark> & \"quotes\"", "&lt;mark&gt;fake&lt;/mark&gt; &amp; &#34;quotes&#34;"},
+	}
+
+	for _, test := range tests {
+		if got := headlineHTML(test.headline); got != test.want {
+			t.Errorf("headlineHTML(%q) = %q, want %q", test.headline, got, test.want)
+		}
+	}
+}
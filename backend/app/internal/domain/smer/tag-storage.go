@@ -0,0 +1,103 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	tagsTable     = "tags"
+	smerTagsTable = "smer_tags"
+)
+
+var ErrUnknownTag = errors.New("unknown tag")
+
+// smerTags is a JSON array of the selected smer's tags.
+var smerTags = fmt.Sprintf(
+	"(SELECT COALESCE(json_agg(json_build_object('id', t.id, 'name', t.name, 'color', t.color) ORDER BY lower(t.name)), '[]') "+
+		"FROM %[1]v.%[2]v st JOIN %[1]v.%[3]v t ON t.id = st.tag_id WHERE st.smer_id = %[4]v.id) AS tags",
+	scheme, smerTagsTable, tagsTable, table,
+)
+
+// tagFilter matches smers with the tag.
+func tagFilter(tagId uint16) *db.Filter {
+	return db.NewExprFilter(sq.Expr(fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM %[1]v.%[2]v st WHERE st.smer_id = %[3]v.id AND st.tag_id = ?)",
+		scheme, smerTagsTable, table,
+	), tagId))
+}
+
+// replaceTags sets the tags of the smer, nil keeps the current ones.
+// Only the user's own tags can be assigned, otherwise ErrUnknownTag is returned.
+func (s *Storage) replaceTags(tx pgx.Tx, smerId uint16, userId uint16, tagIds []uint16) error {
+	if tagIds == nil {
+		return nil
+	}
+
+	query := s.queryBuilder.Delete(scheme + "." + smerTagsTable).Where(sq.Eq{"smer_id": smerId})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, smerTagsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	ids := uniqueIds(tagIds)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tags := sq.Select().
+		Column("?::bigint", smerId).
+		Column("id").
+		From(scheme + "." + tagsTable).
+		Where(sq.Eq{"id": ids, "user_id": userId})
+
+	insert := s.queryBuilder.Insert(scheme+"."+smerTagsTable).
+		Columns("smer_id", "tag_id").
+		Select(tags)
+
+	sql, args, err = insert.ToSql()
+	logger = s.queryLogger(sql, smerTagsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	tag, err := tx.Exec(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+	if tag.RowsAffected() != int64(len(ids)) {
+		return ErrUnknownTag
+	}
+
+	return nil
+}
+
+func uniqueIds(ids []uint16) []uint16 {
+	seen := make(map[uint16]bool, len(ids))
+	result := make([]uint16, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package smer
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+// vocabularyColumns are the word lists a vocabulary can be built from.
+// The value tells whether a merge collapses repeated words, thoughts keep
+// their positions because distortions and reframes are bound to them.
+var vocabularyColumns = map[string]bool{
+	"emotions":  true,
+	"reactions": true,
+	"thoughts":  false,
+}
+
+// MergeWords replaces every word of from with to in the column of all the
+// user's smers, the trashed ones included, so a restored smer matches the rest.
+// In emotions and reactions to is only kept at its first position, emotion
+// ratings are collapsed the same way. Every changed smer gets a new revision.
+// Returns the number of changed smers.
+func (s *Storage) MergeWords(userId uint16, column string, from []string, to string) (int, error) {
+	collapse, ok := vocabularyColumns[column]
+	if !ok {
+		return 0, fmt.Errorf("unknown vocabulary column: %v", column)
+	}
+
+	replaced := sq.Expr(fmt.Sprintf(
+		"(SELECT array_agg(CASE WHEN w.word = ANY(?) THEN ? ELSE w.word END ORDER BY w.position) "+
+			"FROM unnest(%[1]v.%[2]v) WITH ORDINALITY AS w(word, position))",
+		table, column,
+	), from, to)
+	if collapse {
+		replaced = sq.Expr(fmt.Sprintf(
+			"(SELECT array_agg(m.word ORDER BY m.position) FROM "+
+				"(SELECT r.word, r.position, row_number() OVER (PARTITION BY r.word ORDER BY r.position) AS n FROM "+
+				"(SELECT CASE WHEN w.word = ANY(?) THEN ? ELSE w.word END AS word, w.position "+
+				"FROM unnest(%[1]v.%[2]v) WITH ORDINALITY AS w(word, position)) r) m "+
+				"WHERE m.n = 1 OR m.word <> ?)",
+			table, column,
+		), from, to, to)
+	}
+
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set(column, replaced).
+		Where(sq.Eq{table + ".user_id": userId}).
+		Where(sq.Expr(table+"."+column+" && ?::text[]", from)).
+		Suffix("RETURNING id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return 0, err
+	}
+
+	ids := make([]uint16, 0)
+
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		logger.Trace("Merging words")
+		rows, err := tx.Query(s.ctx, sql, args...)
+		if err != nil {
+			err = db.ErrDoQuery(err)
+			logger.Error(err)
+			return err
+		}
+		for rows.Next() {
+			var id uint16
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				err = db.ErrScan(err)
+				logger.Error(err)
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			err = db.ErrDoQuery(err)
+			logger.Error(err)
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		// Ratings carry the same names as smers.emotions and must stay in sync.
+		if column == "emotions" {
+			if err := s.mergeRatings(tx, ids, from, to); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range ids {
+			if err := s.addRevision(tx, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// mergeRatings renames the rated emotions of the smers like MergeWords does
+// and keeps only the first rating of to, the positions are then renumbered
+// to match smers.emotions again.
+func (s *Storage) mergeRatings(tx pgx.Tx, ids []uint16, from []string, to string) error {
+	rename := s.queryBuilder.Update(scheme+"."+emotionsTable).
+		Set("name", to).
+		Where(sq.Eq{"smer_id": ids, "name": from})
+
+	duplicates := s.queryBuilder.Delete(scheme + "." + emotionsTable + " e").
+		Where(sq.Eq{"e.smer_id": ids, "e.name": to}).
+		Where(fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM %[1]v.%[2]v f WHERE f.smer_id = e.smer_id AND f.name = e.name AND f.position < e.position)",
+			scheme, emotionsTable,
+		))
+
+	// positions are unique, so they are moved out of the way before renumbering
+	negate := s.queryBuilder.Update(scheme+"."+emotionsTable).
+		Set("position", sq.Expr("-1 - position")).
+		Where(sq.Eq{"smer_id": ids})
+
+	numbered := sq.Select("id", "row_number() OVER (PARTITION BY smer_id ORDER BY position DESC) - 1 AS position").
+		From(scheme + "." + emotionsTable).
+		Where(sq.Eq{"smer_id": ids})
+	renumber := s.queryBuilder.Update(scheme+"."+emotionsTable+" e").
+		Set("position", sq.Expr("(SELECT n.position FROM (?) n WHERE n.id = e.id)", numbered)).
+		Where(sq.Eq{"e.smer_id": ids})
+
+	for _, query := range []sq.Sqlizer{rename, duplicates, negate, renumber} {
+		sql, args, err := query.ToSql()
+		logger := s.queryLogger(sql, emotionsTable, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return err
+		}
+
+		logger.Trace("Merging emotion ratings")
+		if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+			err = db.ErrDoQuery(err)
+			logger.Error(err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package tag
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/logging"
+	"backend/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/julienschmidt/httprouter"
+)
+
+type Handler struct {
+	logger  *logging.Logger
+	storage *Storage
+	ctx     context.Context
+}
+
+const (
+	tagsURL = "/api/tags"
+	tagURL  = "/api/tags/:tagId"
+)
+
+func NewTagHandler(ctx context.Context, storage *Storage, logger *logging.Logger) *Handler {
+	return &Handler{
+		logger:  logger,
+		storage: storage,
+		ctx:     ctx,
+	}
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.GET(tagsURL, auth.RequireAuth(h.GetTags))
+	router.POST(tagsURL, auth.RequireAuth(h.CreateTag))
+	router.PATCH(tagURL, auth.RequireAuth(h.UpdateTag))
+	router.DELETE(tagURL, auth.RequireAuth(h.DeleteTag))
+}
+
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	tags, err := h.storage.All(userId)
+	if err != nil {
+		h.logger.Error(err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, tags)
+}
+
+func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	dto, ok := readTagDto(w, r)
+	if !ok {
+		return
+	}
+
+	tagId, err := h.storage.Create(userId, dto)
+	if errors.Is(err, ErrTagExists) {
+		utils.WriteErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusCreated, tagId)
+}
+
+func (h *Handler) UpdateTag(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("tagId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	dto, ok := readTagDto(w, r)
+	if !ok {
+		return
+	}
+
+	err = h.storage.Update(userId, uint16(id), dto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Tag not found")
+		return
+	}
+	if errors.Is(err, ErrTagExists) {
+		utils.WriteErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.ParseUint(ps.ByName("tagId"), 16, 16)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := r.Context().Value("userId").(uint16)
+
+	err = h.storage.Delete(userId, uint16(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Tag not found")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, id)
+}
+
+// readTagDto reads and validates the request body, on failure the response is already written.
+func readTagDto(w http.ResponseWriter, r *http.Request) (TagDto, bool) {
+	var dto TagDto
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	if err := json.Unmarshal(body, &dto); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	if err := dto.Validate(); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	return dto, true
+}
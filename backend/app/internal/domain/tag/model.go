@@ -0,0 +1,44 @@
+package tag
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const maxNameLength = 50
+
+// colorPattern is a #rrggbb color.
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type Tag struct {
+	Id        uint16    `json:"id" sql:"id"`
+	Name      string    `json:"name" sql:"name"`
+	Color     string    `json:"color" sql:"color"`
+	CreatedAt time.Time `json:"createdAt" sql:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" sql:"updated_at"`
+
+	// UsageCount is the number of the user's smers (outside the trash) with the tag.
+	UsageCount uint64 `json:"usageCount"`
+}
+
+type TagDto struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func (dto *TagDto) Validate() error {
+	dto.Name = strings.TrimSpace(dto.Name)
+	if len(dto.Name) == 0 {
+		return errors.New("tag name is empty")
+	}
+	if len([]rune(dto.Name)) > maxNameLength {
+		return fmt.Errorf("tag name is longer than %d characters", maxNameLength)
+	}
+	if !colorPattern.MatchString(dto.Color) {
+		return fmt.Errorf("invalid color: '%v', expected #rrggbb", dto.Color)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package tag
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewTagStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme        = "public"
+	table         = "tags"
+	smerTagsTable = "smer_tags"
+	smersTable    = "smers"
+)
+
+var ErrTagExists = errors.New("tag with this name already exists")
+
+// usageCount is the number of not trashed smers with the selected tag.
+var usageCount = fmt.Sprintf(
+	"(SELECT COUNT(*) FROM %[1]v.%[2]v st JOIN %[1]v.%[3]v s ON s.id = st.smer_id "+
+		"WHERE st.tag_id = %[4]v.id AND s.deleted_at IS NULL) AS usage_count",
+	scheme, smerTagsTable, smersTable, table,
+)
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// All returns the user's tags with usage counts, ordered by name.
+func (s *Storage) All(userId uint16) ([]Tag, error) {
+	query := s.queryBuilder.Select("id", "name", "color", "created_at", "updated_at", usageCount).
+		From(scheme + "." + table).
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("lower(name)")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Tag, 0)
+
+	for rows.Next() {
+		t := Tag{}
+		if err = rows.Scan(&t.Id, &t.Name, &t.Color, &t.CreatedAt, &t.UpdatedAt, &t.UsageCount); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, t)
+	}
+
+	return list, nil
+}
+
+func (s *Storage) Create(userId uint16, dto TagDto) (uint16, error) {
+	lastInsertId := uint16(0)
+
+	query := s.queryBuilder.Insert(scheme+"."+table).
+		Columns("user_id", "name", "color").
+		Values(userId, dto.Name, dto.Color).
+		Suffix("RETURNING id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	logger.Trace("Creating tag")
+	err = s.client.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return lastInsertId, ErrTagExists
+		}
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	return lastInsertId, nil
+}
+
+// Update returns pgx.ErrNoRows when the user has no such tag.
+func (s *Storage) Update(userId uint16, id uint16, dto TagDto) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("name", dto.Name).
+		Set("color", dto.Color).
+		Where(sq.Eq{"id": id, "user_id": userId})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Updating tag")
+	tag, err := s.client.Exec(s.ctx, sql, args...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrTagExists
+		}
+		logger.Error(err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// Delete removes the tag from all smers. Returns pgx.ErrNoRows when the user has no such tag.
+func (s *Storage) Delete(userId uint16, id uint16) error {
+	query := s.queryBuilder.Delete(scheme + "." + table).
+		Where(sq.Eq{"id": id, "user_id": userId})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Deleting tag")
+	tag, err := s.client.Exec(s.ctx, sql, args...)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
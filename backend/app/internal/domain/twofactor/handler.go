@@ -0,0 +1,152 @@
+package twofactor
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/logging"
+	"backend/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type Handler struct {
+	logger  *logging.Logger
+	storage *Storage
+	ctx     context.Context
+}
+
+const (
+	totpURL          = "/api/auth/totp"
+	enrollURL        = "/api/auth/totp/enroll"
+	enableURL        = "/api/auth/totp/enable"
+	disableURL       = "/api/auth/totp/disable"
+	recoveryCodesURL = "/api/auth/totp/recovery-codes"
+)
+
+func NewTwoFactorHandler(ctx context.Context, storage *Storage, logger *logging.Logger) *Handler {
+	return &Handler{
+		logger:  logger,
+		storage: storage,
+		ctx:     ctx,
+	}
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.GET(totpURL, auth.RequireAuth(h.GetStatus))
+	router.POST(enrollURL, auth.RequireAuth(h.Enroll))
+	router.POST(enableURL, auth.RequireAuth(h.Enable))
+	router.POST(disableURL, auth.RequireAuth(h.Disable))
+	router.POST(recoveryCodesURL, auth.RequireAuth(h.RegenerateRecoveryCodes))
+}
+
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	status, err := h.storage.Status(userId)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, status)
+}
+
+func (h *Handler) Enroll(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	enrollment, err := h.storage.Enroll(userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, enrollment)
+}
+
+func (h *Handler) Enable(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	dto, ok := readCodeDto(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.storage.Enable(userId, dto.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, RecoveryCodes{Codes: codes})
+}
+
+func (h *Handler) Disable(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	dto, ok := readCodeDto(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.Disable(userId, dto.Code); err != nil {
+		writeError(w, err)
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, userId)
+}
+
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	dto, ok := readCodeDto(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.storage.RegenerateRecoveryCodes(userId, dto.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, RecoveryCodes{Codes: codes})
+}
+
+// writeError maps the storage errors to response statuses.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidCode):
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, ErrAlreadyEnabled):
+		utils.WriteErrorResponse(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotEnrolled), errors.Is(err, ErrNotEnabled):
+		utils.WriteErrorResponse(w, http.StatusNotFound, err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// readCodeDto reads and validates the request body, on failure the response is already written.
+func readCodeDto(w http.ResponseWriter, r *http.Request) (CodeDto, bool) {
+	var dto CodeDto
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	if err := json.Unmarshal(body, &dto); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	if err := dto.Validate(); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return dto, false
+	}
+
+	return dto, true
+}
@@ -0,0 +1,108 @@
+package twofactor
+
+import (
+	"backend/pkg/auth"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/dchest/uniuri"
+)
+
+var (
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidCode    = errors.New("invalid code")
+)
+
+const (
+	recoveryCodes    = 10
+	recoveryCodeHalf = 5
+	// challengeMins is how long the second step may take after the password.
+	challengeMins = 5
+)
+
+// Letters and digits that are hard to confuse when typed from paper.
+var recoveryChars = []byte("abcdefghjkmnpqrstuvwxyz23456789")
+
+type Status struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+// Enrollment is shown once, the uri is usually rendered as a QR code.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
+
+// CodeDto carries a TOTP code or, where allowed, a recovery code.
+type CodeDto struct {
+	Code string `json:"code"`
+}
+
+func (dto CodeDto) Validate() error {
+	if strings.TrimSpace(dto.Code) == "" {
+		return errors.New("code is required")
+	}
+	return nil
+}
+
+// Challenge replaces the tokens of a signin when the second step is required.
+type Challenge struct {
+	TotpRequired bool   `json:"totpRequired"`
+	TotpToken    string `json:"totpToken"`
+}
+
+// NewChallenge issues the short-lived token that the second step is made with.
+func NewChallenge(userId uint16) (*Challenge, error) {
+	claims := auth.ChallengeJwt{
+		Data: auth.ChallengeJwtData{
+			UserId: userId,
+		},
+	}
+
+	token, err := auth.Encode(&claims, challengeMins)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Challenge{
+		TotpRequired: true,
+		TotpToken:    token,
+	}, nil
+}
+
+// ChallengeUser returns the user who passed the first step, 0 for a bad token.
+func ChallengeUser(token string) uint16 {
+	_, claims, err := auth.Decode(&auth.ChallengeJwt{}, token)
+	if err != nil {
+		return 0
+	}
+	return claims.Data.UserId
+}
+
+func newRecoveryCodes() []string {
+	codes := make([]string, recoveryCodes)
+	for i := range codes {
+		codes[i] = uniuri.NewLenChars(recoveryCodeHalf, recoveryChars) + "-" +
+			uniuri.NewLenChars(recoveryCodeHalf, recoveryChars)
+	}
+	return codes
+}
+
+// hashCode is what is stored instead of a recovery code, which is
+// normalized first so that case, dashes and spaces do not matter.
+func hashCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
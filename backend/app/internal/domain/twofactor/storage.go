@@ -0,0 +1,391 @@
+package twofactor
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"backend/pkg/totp"
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+	totp         *totp.TOTP
+	issuer       string
+}
+
+func NewTwoFactorStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger, issuer string) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+		totp:         totp.New(),
+		issuer:       issuer,
+	}
+}
+
+const (
+	scheme     = "public"
+	table      = "user_totp"
+	codesTable = "recovery_codes"
+	usersTable = "users"
+)
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// secretQuery is a query that writes or reads the secret, its args are not logged.
+type secretQuery struct {
+	sq.Sqlizer
+}
+
+func (s *Storage) secretQueryLogger(sql, table string) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  "[redacted]",
+	})
+}
+
+func (s *Storage) Status(userId uint16) (*Status, error) {
+	var status Status
+
+	enabled := sq.Select("enabled_at IS NOT NULL").
+		From(scheme + "." + table).
+		Where(sq.Eq{"user_id": userId})
+	codesLeft := sq.Select("COUNT(*)").
+		From(scheme + "." + codesTable).
+		Where(sq.Eq{"user_id": userId, "used_at": nil})
+
+	query := s.queryBuilder.Select().
+		Column(sq.Expr("COALESCE((?), FALSE)", enabled)).
+		Column(sq.Expr("(?)", codesLeft))
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&status.Enabled, &status.RecoveryCodesLeft); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Enroll generates a new pending secret, replacing a pending one.
+// The secret is not used until Enable confirms the user's app has it.
+func (s *Storage) Enroll(userId uint16) (*Enrollment, error) {
+	var email string
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.logger.Error(err)
+		return nil, err
+	}
+
+	emailQuery := s.queryBuilder.Select("email").
+		From(scheme + "." + usersTable).
+		Where(sq.Eq{"id": userId})
+
+	sql, args, err := emailQuery.ToSql()
+	logger := s.queryLogger(sql, usersTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&email); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	query := secretQuery{s.queryBuilder.Insert(scheme+"."+table).
+		Columns("user_id", "secret").
+		Values(userId, secret).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = NULL " +
+			"WHERE " + table + ".enabled_at IS NULL")}
+
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		affected, err := s.exec(tx, query, table, "Enrolling totp")
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrAlreadyEnabled
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: secret,
+		Uri:    s.totp.URI(s.issuer, email, secret),
+	}, nil
+}
+
+// Enable turns the pending secret on once the user proves their app
+// generates its codes, and returns the first recovery codes.
+func (s *Storage) Enable(userId uint16, code string) ([]string, error) {
+	var codes []string
+
+	query := s.queryBuilder.Select("secret", "enabled_at").
+		From(scheme + "." + table).
+		Where(sq.Eq{"user_id": userId}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := query.ToSql()
+	logger := s.secretQueryLogger(sql, table)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		var secret string
+		var enabledAt *time.Time
+
+		err := tx.QueryRow(s.ctx, sql, args...).Scan(&secret, &enabledAt)
+		if err == pgx.ErrNoRows {
+			return ErrNotEnrolled
+		}
+		if err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return err
+		}
+		if enabledAt != nil {
+			return ErrAlreadyEnabled
+		}
+
+		step, ok, err := s.totp.Validate(secret, code)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		enable := s.queryBuilder.Update(scheme+"."+table).
+			Set("enabled_at", sq.Expr("NOW()")).
+			Set("last_step", step).
+			Where(sq.Eq{"user_id": userId})
+
+		if _, err = s.exec(tx, enable, table, "Enabling totp"); err != nil {
+			return err
+		}
+
+		codes, err = s.replaceRecoveryCodes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Check accepts a TOTP code or an unused recovery code of the user,
+// either can be used only once.
+func (s *Storage) Check(userId uint16, code string) error {
+	return s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		return s.check(tx, userId, code)
+	})
+}
+
+// Disable turns two-factor authentication off and removes the recovery codes.
+func (s *Storage) Disable(userId uint16, code string) error {
+	return s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		if err := s.check(tx, userId, code); err != nil {
+			return err
+		}
+
+		queries := []struct {
+			table string
+			query sq.Sqlizer
+		}{
+			{codesTable, s.queryBuilder.Delete(scheme + "." + codesTable).
+				Where(sq.Eq{"user_id": userId})},
+			{table, s.queryBuilder.Delete(scheme + "." + table).
+				Where(sq.Eq{"user_id": userId})},
+		}
+
+		for _, q := range queries {
+			if _, err := s.exec(tx, q.query, q.table, "Disabling totp"); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces all of the recovery codes, used or not.
+func (s *Storage) RegenerateRecoveryCodes(userId uint16, code string) ([]string, error) {
+	var codes []string
+
+	err := s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		if err := s.check(tx, userId, code); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// check locks the user's secret, so the same code can not pass twice concurrently.
+func (s *Storage) check(tx pgx.Tx, userId uint16, code string) error {
+	var secret string
+	var lastStep *uint64
+
+	query := s.queryBuilder.Select("secret", "last_step").
+		From(scheme + "." + table).
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.NotEq{"enabled_at": nil}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := query.ToSql()
+	logger := s.secretQueryLogger(sql, table)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	err = tx.QueryRow(s.ctx, sql, args...).Scan(&secret, &lastStep)
+	if err == pgx.ErrNoRows {
+		return ErrNotEnabled
+	}
+	if err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return err
+	}
+
+	code = strings.TrimSpace(code)
+
+	if !s.isTotpCode(code) {
+		useCode := s.queryBuilder.Update(scheme+"."+codesTable).
+			Set("used_at", sq.Expr("NOW()")).
+			Where(sq.Eq{"user_id": userId, "code_hash": hashCode(code), "used_at": nil})
+
+		affected, err := s.exec(tx, useCode, codesTable, "Using recovery code")
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	step, ok, err := s.totp.Validate(secret, code)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if !ok || totp.Replayed(step, lastStep) {
+		return ErrInvalidCode
+	}
+
+	useStep := s.queryBuilder.Update(scheme+"."+table).
+		Set("last_step", step).
+		Where(sq.Eq{"user_id": userId})
+
+	_, err = s.exec(tx, useStep, table, "Using totp code")
+	return err
+}
+
+func (s *Storage) isTotpCode(code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != s.totp.Digits {
+		return false
+	}
+	for _, r := range code {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceRecoveryCodes returns the new codes, only their hashes are kept.
+func (s *Storage) replaceRecoveryCodes(tx pgx.Tx, userId uint16) ([]string, error) {
+	codes := newRecoveryCodes()
+
+	insert := s.queryBuilder.Insert(scheme+"."+codesTable).
+		Columns("user_id", "code_hash")
+	for _, code := range codes {
+		insert = insert.Values(userId, hashCode(code))
+	}
+
+	queries := []sq.Sqlizer{
+		s.queryBuilder.Delete(scheme + "." + codesTable).
+			Where(sq.Eq{"user_id": userId}),
+		insert,
+	}
+
+	for _, query := range queries {
+		if _, err := s.exec(tx, query, codesTable, "Replacing recovery codes"); err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+func (s *Storage) exec(tx pgx.Tx, query sq.Sqlizer, table, message string) (int64, error) {
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if _, ok := query.(secretQuery); ok {
+		logger = s.secretQueryLogger(sql, table)
+	}
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return 0, err
+	}
+
+	logger.Trace(message)
+	tag, err := tx.Exec(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
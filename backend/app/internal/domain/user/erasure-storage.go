@@ -0,0 +1,266 @@
+package user
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	erasuresTable     = "account_erasures"
+	sharesTable       = "shares"
+	smersTable        = "smers"
+	smerCommentsTable = "smer_comments"
+)
+
+// ScheduleErasure plans the erasure of the account after the grace period.
+// Scheduling again keeps the original date.
+func (s *Storage) ScheduleErasure(userId uint16, gracePeriod time.Duration) (*Erasure, error) {
+	var erasure Erasure
+
+	query := s.queryBuilder.Insert(scheme+"."+erasuresTable).
+		Columns("user_id", "scheduled_at").
+		Values(userId, time.Now().Add(gracePeriod)).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id RETURNING scheduled_at, created_at")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, erasuresTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	logger.Trace("Scheduling erasure")
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&erasure.ScheduledAt, &erasure.CreatedAt); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &erasure, nil
+}
+
+func (s *Storage) GetErasure(userId uint16) (*Erasure, error) {
+	var erasure Erasure
+
+	query := s.queryBuilder.Select("scheduled_at", "created_at").
+		From(scheme + "." + erasuresTable).
+		Where(sq.Eq{"user_id": userId})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, erasuresTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&erasure.ScheduledAt, &erasure.CreatedAt); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			err = db.ErrScan(err)
+			logger.Error(err)
+		}
+		return nil, err
+	}
+
+	return &erasure, nil
+}
+
+// CancelErasure returns pgx.ErrNoRows when nothing was scheduled.
+func (s *Storage) CancelErasure(userId uint16) error {
+	query := s.queryBuilder.Delete(scheme + "." + erasuresTable).
+		Where(sq.Eq{"user_id": userId})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, erasuresTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Cancelling erasure")
+	tag, err := s.client.Exec(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// EraseDue erases one account whose grace period is over.
+// The erasure row stays locked until the account is gone, so a cancel
+// either happens before or waits and finds nothing to cancel.
+// It returns the id of the erased user (0 when nothing is due) and the
+// files to remove from disk once the transaction is committed.
+func (s *Storage) EraseDue() (uint16, []string, error) {
+	var userId uint16
+	var paths []string
+
+	err := s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		query := s.queryBuilder.Select("user_id").
+			From(scheme + "." + erasuresTable).
+			Where("scheduled_at <= NOW()").
+			OrderBy("scheduled_at").
+			Limit(1).
+			Suffix("FOR UPDATE SKIP LOCKED")
+
+		sql, args, err := query.ToSql()
+		logger := s.queryLogger(sql, erasuresTable, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return err
+		}
+
+		if err = tx.QueryRow(s.ctx, sql, args...).Scan(&userId); err != nil {
+			return err
+		}
+
+		paths, err = s.erase(tx, userId)
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		s.logger.Error(err)
+		return 0, nil, err
+	}
+
+	return userId, paths, nil
+}
+
+// erase removes the user and everything that belongs to them.
+// Replies of other users to the user's comments are kept as top level comments.
+func (s *Storage) erase(tx pgx.Tx, userId uint16) ([]string, error) {
+	paths := make([]string, 0)
+
+	exportPaths := s.queryBuilder.Select("path").
+		From(scheme + "." + exportsTable).
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.NotEq{"path": nil})
+
+	sql, args, err := exportPaths.ToSql()
+	logger := s.queryLogger(sql, exportsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := tx.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+	for rows.Next() {
+		var path string
+		if err = rows.Scan(&path); err != nil {
+			rows.Close()
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+
+	authored := sq.Select("id").
+		From(scheme + "." + smerCommentsTable).
+		Where(sq.Eq{"author_id": userId})
+
+	var avatarId *uint16
+
+	queries := []struct {
+		table string
+		query sq.Sqlizer
+	}{
+		{smerCommentsTable, s.queryBuilder.Update(scheme+"."+smerCommentsTable).
+			Set("parent_id", nil).
+			Where(sq.Expr("parent_id IN (?)", authored)).
+			Where(sq.NotEq{"author_id": userId})},
+		{smerCommentsTable, s.queryBuilder.Delete(scheme + "." + smerCommentsTable).
+			Where(sq.Eq{"author_id": userId})},
+		{sharesTable, s.queryBuilder.Delete(scheme + "." + sharesTable).
+			Where(sq.Or{sq.Eq{"client_id": userId}, sq.Eq{"therapist_id": userId}})},
+		{smersTable, s.queryBuilder.Delete(scheme + "." + smersTable).
+			Where(sq.Eq{"user_id": userId})},
+		{tokensTable, s.queryBuilder.Delete(scheme + "." + tokensTable).
+			Where(sq.Eq{"user_id": userId})},
+	}
+
+	for _, q := range queries {
+		sql, args, err := q.query.ToSql()
+		logger := s.queryLogger(sql, q.table, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		logger.Trace("Erasing user data")
+		if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+			err = db.ErrDoQuery(err)
+			logger.Error(err)
+			return nil, err
+		}
+	}
+
+	// Exports and the erasure itself go with the user row.
+	deleteUser := s.queryBuilder.Delete(scheme + "." + table).
+		Where(sq.Eq{"id": userId}).
+		Suffix("RETURNING avatar_id")
+
+	sql, args, err = deleteUser.ToSql()
+	logger = s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	logger.Trace("Erasing user")
+	if err = tx.QueryRow(s.ctx, sql, args...).Scan(&avatarId); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	// The avatar file is the one of the erased row.
+	if avatarId != nil {
+		var avatarPath string
+
+		deleteAvatar := s.queryBuilder.Delete(scheme + "." + filesTable).
+			Where(sq.Eq{"id": *avatarId}).
+			Suffix("RETURNING path")
+
+		sql, args, err = deleteAvatar.ToSql()
+		logger = s.queryLogger(sql, filesTable, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		if err = tx.QueryRow(s.ctx, sql, args...).Scan(&avatarPath); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+		paths = append(paths, avatarPath)
+	}
+
+	return paths, nil
+}
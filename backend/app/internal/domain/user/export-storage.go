@@ -0,0 +1,321 @@
+package user
+
+import (
+	db "backend/pkg/client/postgresql/model"
+	"errors"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	exportsTable = "account_exports"
+	filesTable   = "files"
+)
+
+var exportColumns = []string{"id", "user_id", "status", "path", "error", "expires_at", "created_at"}
+
+func scanExport(row pgx.Row, export *Export) error {
+	return row.Scan(
+		&export.Id, &export.UserId, &export.Status, &export.Path, &export.Error, &export.ExpiresAt, &export.CreatedAt,
+	)
+}
+
+// CreateExport queues a new archive for the user, a pending one is reused.
+func (s *Storage) CreateExport(userId uint16) (*Export, error) {
+	var export Export
+
+	pending := s.queryBuilder.Select(exportColumns...).
+		From(scheme + "." + exportsTable).
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Eq{"status": []string{ExportStatusPending, ExportStatusProcessing}}).
+		OrderBy("id DESC").
+		Limit(1)
+
+	sql, args, err := pending.ToSql()
+	logger := s.queryLogger(sql, exportsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	err = scanExport(s.client.QueryRow(s.ctx, sql, args...), &export)
+	if err == nil {
+		return &export, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	query := s.queryBuilder.Insert(scheme + "." + exportsTable).
+		Columns("user_id").
+		Values(userId).
+		Suffix("RETURNING " + strings.Join(exportColumns, ", "))
+
+	sql, args, err = query.ToSql()
+	logger = s.queryLogger(sql, exportsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	logger.Trace("Creating export")
+	if err = scanExport(s.client.QueryRow(s.ctx, sql, args...), &export); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+func (s *Storage) Exports(userId uint16) ([]Export, error) {
+	query := s.queryBuilder.Select(exportColumns...).
+		From(scheme + "." + exportsTable).
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("id DESC")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, exportsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Export, 0)
+	for rows.Next() {
+		var export Export
+		if err = scanExport(rows, &export); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+		list = append(list, export)
+	}
+
+	return list, nil
+}
+
+// ReadyExport finds a built archive by its download token, expired ones are not returned.
+func (s *Storage) ReadyExport(token string) (*Export, error) {
+	var export Export
+
+	query := s.queryBuilder.Select(exportColumns...).
+		From(scheme + "." + exportsTable).
+		Where(sq.Eq{"token_hash": hashToken(token), "status": ExportStatusReady}).
+		Where("expires_at > NOW()")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, exportsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = scanExport(s.client.QueryRow(s.ctx, sql, args...), &export); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			err = db.ErrScan(err)
+		}
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// ClaimExport takes the oldest pending export for processing.
+// Locked rows are skipped, so several workers never build the same archive.
+// An export stuck in processing longer than timeout (its worker died) is taken again.
+func (s *Storage) ClaimExport(timeout time.Duration) (*Export, error) {
+	var export Export
+
+	pending := sq.Select("id").
+		From(scheme + "." + exportsTable).
+		Where(sq.Or{
+			sq.Eq{"status": ExportStatusPending},
+			sq.And{
+				sq.Eq{"status": ExportStatusProcessing},
+				sq.Lt{"updated_at": time.Now().Add(-timeout)},
+			},
+		}).
+		OrderBy("id").
+		Limit(1).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	query := s.queryBuilder.Update(scheme+"."+exportsTable).
+		Set("status", ExportStatusProcessing).
+		Where(sq.Expr("id = (?)", pending)).
+		Suffix("RETURNING " + strings.Join(exportColumns, ", "))
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, exportsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = scanExport(s.client.QueryRow(s.ctx, sql, args...), &export); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			err = db.ErrScan(err)
+			logger.Error(err)
+		}
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// CompleteExport marks the archive as built. Only the hash of the download
+// token is stored, the token itself goes out in the mail.
+func (s *Storage) CompleteExport(id uint16, path string, token string, expiresAt time.Time) error {
+	query := s.queryBuilder.Update(scheme+"."+exportsTable).
+		Set("status", ExportStatusReady).
+		Set("path", path).
+		Set("token_hash", hashToken(token)).
+		Set("expires_at", expiresAt).
+		Where(sq.Eq{"id": id})
+
+	return s.exec(query, exportsTable, "Completing export")
+}
+
+func (s *Storage) FailExport(id uint16, exportErr error) error {
+	query := s.queryBuilder.Update(scheme+"."+exportsTable).
+		Set("status", ExportStatusFailed).
+		Set("error", exportErr.Error()).
+		Where(sq.Eq{"id": id})
+
+	return s.exec(query, exportsTable, "Failing export")
+}
+
+// ExpireExports removes archives past their expiration and returns their files.
+func (s *Storage) ExpireExports() ([]string, error) {
+	query := s.queryBuilder.Delete(scheme + "." + exportsTable).
+		Where("expires_at <= NOW()").
+		Suffix("RETURNING path")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, exportsTable, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	paths := make([]string, 0)
+	for rows.Next() {
+		var path *string
+		if err = rows.Scan(&path); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+		if path != nil {
+			paths = append(paths, *path)
+		}
+	}
+
+	return paths, nil
+}
+
+// Profile returns the user's row without secrets.
+func (s *Storage) Profile(id uint16) (*Profile, error) {
+	var profile Profile
+
+	query := s.queryBuilder.Select(
+		"id", "email", "username", "name", "surname", "patronymic",
+		"COALESCE(is_verified, FALSE)", "COALESCE(is_oauth, FALSE)", "created_at", "updated_at",
+	).
+		From(scheme + "." + table).
+		Where(sq.Eq{"id": id})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(
+		&profile.Id, &profile.Email, &profile.Username, &profile.Name, &profile.Surname, &profile.Patronymic,
+		&profile.IsVerified, &profile.IsOAuth, &profile.CreatedAt, &profile.UpdatedAt,
+	); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// AvatarPath returns the path of the user's avatar file, nil when there is none.
+func (s *Storage) AvatarPath(id uint16) (*string, error) {
+	var path *string
+
+	query := s.queryBuilder.Select("f.path").
+		From(scheme + "." + table + " u").
+		LeftJoin(scheme + "." + filesTable + " f ON f.id = u.avatar_id").
+		Where(sq.Eq{"u.id": id})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = s.client.QueryRow(s.ctx, sql, args...).Scan(&path); err != nil {
+		err = db.ErrScan(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	return path, nil
+}
+
+func (s *Storage) exec(query sq.Sqlizer, table, message string) error {
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace(message)
+	if _, err = s.client.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package user
+
+import (
+	"archive/zip"
+	"backend/internal/domain/reminder"
+	"backend/internal/domain/smer"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type SmerStorage interface {
+	EachArchived(userId uint16, fn func(smer.ArchivedSmer) error) error
+}
+
+type ReminderStorage interface {
+	All(userId uint16) ([]reminder.Reminder, error)
+}
+
+// writeArchive puts the profile, all smers with their nested data, the reminders
+// and the avatar into a ZIP. Smers are streamed, so the archive never holds
+// the whole diary in memory.
+func writeArchive(w io.Writer, profile *Profile, smers SmerStorage, reminders ReminderStorage, avatarPath *string) error {
+	archive := zip.NewWriter(w)
+
+	file, err := archive.Create("profile.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(profile); err != nil {
+		return err
+	}
+
+	file, err = archive.Create("smers.json")
+	if err != nil {
+		return err
+	}
+	if err = writeSmers(file, profile.Id, smers); err != nil {
+		return err
+	}
+
+	list, err := reminders.All(profile.Id)
+	if err != nil {
+		return err
+	}
+	file, err = archive.Create("reminders.json")
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(list); err != nil {
+		return err
+	}
+
+	if avatarPath != nil {
+		if err = copyToArchive(archive, "avatar"+filepath.Ext(*avatarPath), *avatarPath); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writeSmers(w io.Writer, userId uint16, smers SmerStorage) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := smers.EachArchived(userId, func(item smer.ArchivedSmer) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+func copyToArchive(archive *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) {
+		// the avatar row may outlive its file, there is nothing to export then
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"backend/internal/config"
 	"backend/pkg/auth"
 	"backend/pkg/client/postgresql/model"
 	"backend/pkg/logging"
@@ -8,11 +9,13 @@ import (
 	"backend/pkg/utils"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
 	"github.com/julienschmidt/httprouter"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 )
 
 type Handler struct {
@@ -20,6 +23,7 @@ type Handler struct {
 	storage      *Storage
 	filesStorage FilesStorage
 	ctx          context.Context
+	cfg          *config.Config
 }
 
 type FilesStorage interface {
@@ -28,16 +32,20 @@ type FilesStorage interface {
 }
 
 const (
-	usersURL = "/api/users"
-	userURL  = "/api/users/:userId"
+	usersURL   = "/api/users"
+	userURL    = "/api/users/:userId"
+	erasureURL = "/api/users/erasure"
+	exportsURL = "/api/users/exports"
+	exportURL  = "/api/users/exports/:token"
 )
 
-func NewUserHandler(ctx context.Context, storage *Storage, logger *logging.Logger, filesStorage FilesStorage) *Handler {
+func NewUserHandler(ctx context.Context, storage *Storage, logger *logging.Logger, filesStorage FilesStorage, cfg *config.Config) *Handler {
 	return &Handler{
 		filesStorage: filesStorage,
 		logger:       logger,
 		storage:      storage,
 		ctx:          ctx,
+		cfg:          cfg,
 	}
 }
 
@@ -45,12 +53,37 @@ func (h *Handler) Register(router *httprouter.Router) {
 	router.GET(usersURL, auth.RequireAuth(h.GetUser))
 	router.PATCH(usersURL, auth.RequireAuth(h.UpdateUser))
 	router.DELETE(usersURL, auth.RequireAuth(h.DeleteUser))
+	router.GET(erasureURL, auth.RequireAuth(h.GetErasure))
+	router.DELETE(erasureURL, auth.RequireAuth(h.CancelErasure))
+	router.GET(exportsURL, auth.RequireAuth(h.GetExports))
+	router.POST(exportsURL, auth.RequireAuth(h.CreateExport))
+	// the link is sent by email, the token itself grants access
+	router.GET(exportURL, h.DownloadExport)
+}
+
+// listFields are the user fields a list can be sorted by.
+var listFields = model.Fields{
+	"email":     {Column: "email", Type: model.FieldText},
+	"username":  {Column: "username", Type: model.FieldText},
+	"name":      {Column: "name", Type: model.FieldText},
+	"surname":   {Column: "surname", Type: model.FieldText},
+	"createdAt": {Column: "created_at", Type: model.FieldTime},
+	"updatedAt": {Column: "updated_at", Type: model.FieldTime},
 }
 
 func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	pagination, err := model.NewPagination(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sorts, err := model.NewSorts(r, listFields)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	users, err := h.storage.All(nil, pagination)
+	users, err := h.storage.All(nil, pagination, sorts...)
 	if err != nil {
 		h.logger.Error(err)
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
@@ -137,16 +170,84 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request, ps httprout
 	utils.WriteResponse(w, http.StatusOK, id)
 }
 
-func (h *Handler) DeleteUser(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
-	id, err := strconv.ParseUint(ps.ByName("userId"), 16, 16)
+// DeleteUser schedules the erasure of the account, it can be cancelled
+// until the grace period is over.
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.Context().Value("userId").(uint16)
+
+	erasure, err := h.storage.ScheduleErasure(id, h.cfg.Account.ErasureGracePeriod)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusAccepted, erasure)
+}
+
+func (h *Handler) GetErasure(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.Context().Value("userId").(uint16)
+
+	erasure, err := h.storage.GetErasure(id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Erasure is not scheduled")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, erasure)
+}
+
+func (h *Handler) CancelErasure(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.Context().Value("userId").(uint16)
+
+	err := h.storage.CancelErasure(id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Erasure is not scheduled")
 		return
 	}
-	err = h.storage.Delete(uint16(id))
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	utils.WriteResponse(w, http.StatusOK, id)
 }
+
+func (h *Handler) GetExports(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.Context().Value("userId").(uint16)
+
+	exports, err := h.storage.Exports(id)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, exports)
+}
+
+// CreateExport only queues the archive, the worker emails the link when it is built.
+func (h *Handler) CreateExport(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.Context().Value("userId").(uint16)
+
+	export, err := h.storage.CreateExport(id)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusAccepted, export)
+}
+
+func (h *Handler) DownloadExport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	export, err := h.storage.ReadyExport(ps.ByName("token"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Export not found or expired")
+		return
+	}
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"smer-data-%v.zip\"", export.CreatedAt.Format("2006-01-02")))
+	http.ServeFile(w, r, *export.Path)
+}
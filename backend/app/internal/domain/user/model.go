@@ -1,6 +1,10 @@
 package user
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
 
 type User struct {
 	Id         uint16    `json:"id" sql:"id"`
@@ -25,3 +29,56 @@ type VerificationData struct {
 	ExpiresAt time.Time `json:"expiresAt" sql:"expires_at"`
 	Type      string    `json:"type" sql:"type"`
 }
+
+const (
+	ExportStatusPending    = "pending"
+	ExportStatusProcessing = "processing"
+	ExportStatusReady      = "ready"
+	ExportStatusFailed     = "failed"
+)
+
+// Export is a "download my data" archive request.
+type Export struct {
+	Id        uint16     `json:"id" sql:"id"`
+	UserId    uint16     `json:"-" sql:"user_id"`
+	Status    string     `json:"status" sql:"status"`
+	Path      *string    `json:"-" sql:"path"`
+	Error     *string    `json:"error,omitempty" sql:"error"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" sql:"expires_at"`
+	CreatedAt time.Time  `json:"createdAt" sql:"created_at"`
+}
+
+// hashToken is what is stored instead of the download token. Tokens are
+// long and random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Erasure is a scheduled account erasure, it can be cancelled until ScheduledAt.
+type Erasure struct {
+	ScheduledAt time.Time `json:"scheduledAt" sql:"scheduled_at"`
+	CreatedAt   time.Time `json:"createdAt" sql:"created_at"`
+}
+
+// Profile is the user's own data as it goes to the export archive.
+type Profile struct {
+	Id         uint16    `json:"id"`
+	Email      string    `json:"email"`
+	Username   *string   `json:"username"`
+	Name       *string   `json:"name"`
+	Surname    *string   `json:"surname"`
+	Patronymic *string   `json:"patronymic"`
+	IsVerified bool      `json:"isVerified"`
+	IsOAuth    bool      `json:"isOAuth"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Signup announces a new account that has to confirm its email.
+type Signup struct {
+	UserId uint16 `json:"userId"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Token  string `json:"token"`
+}
@@ -0,0 +1,6 @@
+package user
+
+import "backend/pkg/outbox"
+
+// SignupTopic carries the new accounts whose email has to be confirmed.
+var SignupTopic = outbox.NewTopic[Signup]("user.signup")
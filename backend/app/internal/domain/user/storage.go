@@ -6,6 +6,7 @@ import (
 	"backend/pkg/logging"
 	"context"
 
+	"github.com/jackc/pgx/v4"
 	"golang.org/x/crypto/bcrypt"
 
 	db "backend/pkg/client/postgresql/model"
@@ -43,7 +44,9 @@ func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Lo
 	})
 }
 
-func (s *Storage) All(filter *db.Filter, pagination *db.Pagination, sorts ...db.Sort) ([]User, error) {
+// All lists users, by default the newest first. id is always the last
+// sort column, so pages don't overlap when other columns are equal.
+func (s *Storage) All(filter *db.Filter, pagination *db.Pagination, sorts ...*db.Sort) ([]User, error) {
 	query := s.queryBuilder.Select("id", "email", "username", "name", "surname", "patronymic", "is_active", "created_at", "updated_at").
 		From(scheme + "." + table)
 
@@ -53,7 +56,7 @@ func (s *Storage) All(filter *db.Filter, pagination *db.Pagination, sorts ...db.
 	if pagination != nil {
 		query = pagination.UseSelectBuilder(query)
 	}
-	for _, sort := range sorts {
+	for _, sort := range db.WithTiebreaker(sorts, db.NewSort("created_at", "DESC"), "id") {
 		query = sort.UseSelectBuilder(query)
 	}
 
@@ -93,11 +96,19 @@ func (s *Storage) All(filter *db.Filter, pagination *db.Pagination, sorts ...db.
 	return list, nil
 }
 
+// Create adds the user with the activation token in one transaction.
+// A user that signed up with a password also gets a Signup message in
+// the outbox, the confirmation email is sent from there.
 func (s *Storage) Create(user User, isOAuth bool) (uint16, string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	lastInsertId := uint16(0)
 	token := ""
 
+	if err != nil {
+		s.logger.Error(err)
+		return lastInsertId, token, err
+	}
+
 	// Creating user
 	query := s.queryBuilder.Insert(table).
 		Columns("email", "username", "name", "surname", "patronymic", "is_active", "is_verified", "is_oauth", "password").
@@ -112,40 +123,63 @@ func (s *Storage) Create(user User, isOAuth bool) (uint16, string, error) {
 		return lastInsertId, token, err
 	}
 
-	logger.Trace("Creating user")
-	err = s.client.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId)
+	err = s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		logger.Trace("Creating user")
+		if err := tx.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId); err != nil {
+			logger.Error(err)
+			return err
+		}
 
-	if err != nil {
-		logger.Error(err)
-		return lastInsertId, token, err
-	}
+		jwt := auth.LinkJwt{
+			Data: auth.LinkJwtData{
+				Id: lastInsertId,
+			},
+		}
 
-	jwt := auth.LinkJwt{
-		Data: auth.LinkJwtData{
-			Id: lastInsertId,
-		},
-	}
+		var err error
+		token, err = auth.Encode(&jwt, 10)
+		if err != nil {
+			s.logger.Error(err)
+			return err
+		}
 
-	token, err = auth.Encode(&jwt, 10)
+		tokenQuery := s.queryBuilder.Insert(tokensTable).
+			Columns("user_id", "token", "token_type").
+			Values(lastInsertId, token, "ACTIVATE")
 
-	tokenQuery := s.queryBuilder.Insert(tokensTable).
-		Columns("user_id", "token", "token_type").
-		Values(lastInsertId, token, "ACTIVATE")
+		sql, args, err := tokenQuery.ToSql()
+		logger := s.queryLogger(sql, tokensTable, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return err
+		}
 
-	sql, args, err = tokenQuery.ToSql()
-	logger = s.queryLogger(sql, tokensTable, args)
-	if err != nil {
-		err = db.ErrCreateQuery(err)
-		logger.Error(err)
-		return lastInsertId, token, err
-	}
+		logger.Trace("Creating activation token")
+		if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+			logger.Error(err)
+			return err
+		}
 
-	logger.Trace("Creating activation token\n")
-	_, err = s.client.Exec(s.ctx, sql, args...)
+		if isOAuth {
+			return nil
+		}
 
+		signup := Signup{
+			UserId: lastInsertId,
+			Email:  user.Email,
+			Name:   user.Name,
+			Token:  token,
+		}
+		if err = SignupTopic.Add(s.ctx, tx, signup); err != nil {
+			s.logger.Error(err)
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error(err)
-		return lastInsertId, token, err
+		return 0, "", err
 	}
 
 	return lastInsertId, token, nil
@@ -406,74 +440,6 @@ func (s *Storage) ChangePassword(token string, password string) error {
 	return nil
 }
 
-func (s *Storage) IsRefreshTokenActual(token string) (uint16, error) {
-	query := s.queryBuilder.Select("user_id").
-		From(scheme + "." + token).
-		Where(sq.Eq{"token": token, "token_type": "AUTH"})
-
-	sql, args, err := query.ToSql()
-	logger := s.queryLogger(sql, table, args)
-	if err != nil {
-		err = db.ErrCreateQuery(err)
-		logger.Error(err)
-		return 0, err
-	}
-
-	var userId uint16
-
-	row := s.client.QueryRow(s.ctx, sql, args...)
-
-	if err = row.Scan(&userId); err != nil {
-		err = db.ErrScan(err)
-		logger.Error(err)
-		return 0, err
-	}
-
-	return userId, nil
-}
-
-func (s *Storage) UpdateRefreshToken(token string, userId uint16) error {
-	removeQuery := s.queryBuilder.Delete(tokensTable).
-		Where(sq.Eq{"user_id": userId, "token_type": "AUTH"})
-
-	sql, args, err := removeQuery.ToSql()
-	logger := s.queryLogger(sql, tokensTable, args)
-	if err != nil {
-		err = db.ErrCreateQuery(err)
-		logger.Error(err)
-		return err
-	}
-
-	logger.Trace("Deleting refresh (auth) token")
-	_, err = s.client.Exec(s.ctx, sql, args...)
-
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
-
-	insertQuery := s.queryBuilder.Insert(tokensTable).
-		Columns("user_id", "token", "token_type").
-		Values(userId, token, "AUTH")
-
-	sql, args, err = insertQuery.ToSql()
-	logger = s.queryLogger(sql, token, args)
-	if err != nil {
-		err = db.ErrCreateQuery(err)
-		logger.Error(err)
-		return err
-	}
-
-	logger.Trace("Creating new refresh (auth) token")
-	_, err = s.client.Exec(s.ctx, sql, args...)
-
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
-	return nil
-}
-
 // TODO token_type string -> enum (?)
 func (s *Storage) getUserIdByToken(token string, token_type string) (uint16, error) {
 	var userId uint16 = 0
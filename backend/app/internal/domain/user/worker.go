@@ -0,0 +1,171 @@
+package user
+
+import (
+	"backend/internal/config"
+	"backend/pkg/jobs"
+	"backend/pkg/logging"
+	"backend/pkg/mailer"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dchest/uniuri"
+)
+
+const (
+	// exportTimeout is how long an export may stay in processing before another worker retries it.
+	exportTimeout = time.Hour
+	// exportTokenLength is the length of the download token sent by mail.
+	exportTokenLength = 64
+)
+
+// Worker builds requested exports, erases accounts whose grace period is
+// over and removes expired archives. Several instances may run at once.
+type Worker struct {
+	logger    *logging.Logger
+	storage   *Storage
+	smers     SmerStorage
+	reminders ReminderStorage
+	cfg       *config.Config
+	jobQueue  *jobs.Queue
+	ctx       context.Context
+}
+
+func NewAccountWorker(ctx context.Context, storage *Storage, smers SmerStorage, reminders ReminderStorage, logger *logging.Logger, cfg *config.Config, jobQueue *jobs.Queue) *Worker {
+	return &Worker{
+		logger:    logger,
+		storage:   storage,
+		smers:     smers,
+		reminders: reminders,
+		cfg:       cfg,
+		jobQueue:  jobQueue,
+		ctx:       ctx,
+	}
+}
+
+// Run blocks until the context is done.
+func (w *Worker) Run() {
+	ticker := time.NewTicker(w.cfg.Account.WorkerInterval)
+	defer ticker.Stop()
+
+	for {
+		w.processExports()
+		w.processErasures()
+		w.removeExpiredExports()
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) processExports() {
+	for w.ctx.Err() == nil {
+		export, err := w.storage.ClaimExport(exportTimeout)
+		if err != nil {
+			// pgx.ErrNoRows - nothing to do, other errors are already logged
+			return
+		}
+
+		path, err := w.buildExport(export)
+		if err != nil {
+			w.logger.Error(err)
+			w.storage.FailExport(export.Id, err)
+			continue
+		}
+
+		token := uniuri.NewLen(exportTokenLength)
+		expiresAt := time.Now().Add(w.cfg.Account.ExportTTL)
+		if err = w.storage.CompleteExport(export.Id, path, token, expiresAt); err != nil {
+			os.Remove(path)
+			continue
+		}
+
+		if err = w.sendExportLink(export, token, expiresAt); err != nil {
+			w.logger.Error(err)
+		}
+	}
+}
+
+func (w *Worker) buildExport(export *Export) (string, error) {
+	profile, err := w.storage.Profile(export.UserId)
+	if err != nil {
+		return "", err
+	}
+	avatarPath, err := w.storage.AvatarPath(export.UserId)
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.MkdirAll(w.cfg.Account.ExportsDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	path := filepath.Join(w.cfg.Account.ExportsDir, fmt.Sprintf("%d.zip", export.Id))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeArchive(file, profile, w.smers, w.reminders, avatarPath)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+func (w *Worker) sendExportLink(export *Export, token string, expiresAt time.Time) error {
+	profile, err := w.storage.Profile(export.UserId)
+	if err != nil {
+		return err
+	}
+
+	// the API is served under the frontend host, like the other links in mails
+	downloadLink := fmt.Sprintf("%v/api/users/exports/%v", w.cfg.Frontend.ServerIP, token)
+
+	mail := mailer.Mail{
+		Username: profile.Email,
+		Subject:  "SMER diary data export",
+		Text: fmt.Sprintf("Your data archive is ready. Download link (valid until %v): %v",
+			expiresAt.Format(time.RFC1123), downloadLink),
+	}
+	_, err = mailer.SendJob.Enqueue(w.jobQueue, mail)
+	return err
+}
+
+func (w *Worker) processErasures() {
+	for w.ctx.Err() == nil {
+		userId, paths, err := w.storage.EraseDue()
+		if err != nil || userId == 0 {
+			return
+		}
+
+		w.logger.Infof("user %d erased", userId)
+		w.removeFiles(paths)
+	}
+}
+
+func (w *Worker) removeExpiredExports() {
+	paths, err := w.storage.ExpireExports()
+	if err != nil {
+		return
+	}
+	w.removeFiles(paths)
+}
+
+func (w *Worker) removeFiles(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			w.logger.Error(err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package vocabulary
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/logging"
+	"backend/pkg/utils"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// SmerStorage rewrites the words in the user's smers.
+type SmerStorage interface {
+	MergeWords(userId uint16, column string, from []string, to string) (int, error)
+}
+
+type Handler struct {
+	logger      *logging.Logger
+	storage     *Storage
+	smerStorage SmerStorage
+	ctx         context.Context
+}
+
+const (
+	vocabularyURL = "/api/vocabulary/:kind"
+	mergeURL      = "/api/vocabulary/:kind/merge"
+)
+
+func NewVocabularyHandler(ctx context.Context, storage *Storage, logger *logging.Logger, smerStorage SmerStorage) *Handler {
+	return &Handler{
+		logger:      logger,
+		storage:     storage,
+		smerStorage: smerStorage,
+		ctx:         ctx,
+	}
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.GET(vocabularyURL, auth.RequireAuth(h.GetWords))
+	router.POST(mergeURL, auth.RequireAuth(h.MergeWords))
+}
+
+// GetWords suggests words for the prefix, ?limit= caps the list.
+func (h *Handler) GetWords(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	kind := ps.ByName("kind")
+	if _, ok := kinds[kind]; !ok {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Vocabulary not found")
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := uint64(defaultLimit)
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 || parsed > maxLimit {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	words, err := h.storage.Suggest(userId, kind, query.Get("prefix"), limit)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, words)
+}
+
+// MergeWords replaces the synonyms with one word in all of the user's smers.
+func (h *Handler) MergeWords(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userId := r.Context().Value("userId").(uint16)
+
+	column, ok := kinds[ps.ByName("kind")]
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Vocabulary not found")
+		return
+	}
+
+	var dto MergeDto
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := json.Unmarshal(body, &dto); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := dto.Validate(); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updated, err := h.smerStorage.MergeWords(userId, column, dto.From, dto.To)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, MergeResult{Updated: updated})
+}
@@ -0,0 +1,69 @@
+package vocabulary
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	KindEmotions  = "emotions"
+	KindReactions = "reactions"
+	KindThoughts  = "thoughts"
+)
+
+// kinds maps a vocabulary to the smers column it is built from.
+var kinds = map[string]string{
+	KindEmotions:  "emotions",
+	KindReactions: "reactions",
+	KindThoughts:  "thoughts",
+}
+
+const (
+	defaultLimit = 10
+	maxLimit     = 50
+	maxMerged    = 50
+)
+
+type Word struct {
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+
+	// IsDefault marks words of the shared vocabulary, Family is the
+	// basic emotion of the wheel the word belongs to.
+	IsDefault bool    `json:"isDefault"`
+	Family    *string `json:"family,omitempty"`
+}
+
+// MergeDto replaces every word of From with To.
+type MergeDto struct {
+	From []string `json:"from"`
+	To   string   `json:"to"`
+}
+
+type MergeResult struct {
+	Updated int `json:"updated"`
+}
+
+func (dto *MergeDto) Validate() error {
+	dto.To = strings.TrimSpace(dto.To)
+	if len(dto.To) == 0 {
+		return errors.New("merge target is empty")
+	}
+
+	from := make([]string, 0, len(dto.From))
+	for _, word := range dto.From {
+		if word != dto.To && len(strings.TrimSpace(word)) > 0 {
+			from = append(from, word)
+		}
+	}
+	if len(from) == 0 {
+		return errors.New("nothing to merge")
+	}
+	if len(from) > maxMerged {
+		return fmt.Errorf("too many words to merge, at most %d", maxMerged)
+	}
+	dto.From = from
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package vocabulary
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewVocabularyStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme     = "public"
+	table      = "vocabulary_defaults"
+	smersTable = "smers"
+)
+
+// likeEscaper keeps LIKE wildcards typed by the user literal.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// Suggest returns the words starting with the prefix, the user's own first:
+// the most used, then the most recently used, then the shared defaults.
+func (s *Storage) Suggest(userId uint16, kind string, prefix string, limit uint64) ([]Word, error) {
+	column, ok := kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown vocabulary: %v", kind)
+	}
+
+	sql := fmt.Sprintf(`
+WITH used AS (
+    SELECT word AS name, COUNT(*) AS uses, MAX(s.created_at) AS last_used
+    FROM %[1]v.%[2]v s, unnest(s.%[4]v) AS word
+    WHERE s.user_id = $1 AND s.deleted_at IS NULL AND lower(word) LIKE $2
+    GROUP BY word
+),
+defaults AS (
+    SELECT name, family FROM %[1]v.%[3]v WHERE kind = $3 AND lower(name) LIKE $2
+)
+SELECT COALESCE(used.name, defaults.name) AS name,
+       COALESCE(used.uses, 0) AS uses,
+       defaults.name IS NOT NULL,
+       defaults.family
+FROM used
+         FULL JOIN defaults ON defaults.name = used.name
+ORDER BY uses DESC, used.last_used DESC NULLS LAST, name
+LIMIT $4`, scheme, smersTable, table, column)
+
+	args := []interface{}{userId, strings.ToLower(likeEscaper.Replace(prefix)) + "%", kind, limit}
+	logger := s.queryLogger(sql, table, args)
+
+	rows, err := s.client.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Word, 0)
+
+	for rows.Next() {
+		w := Word{}
+		if err = rows.Scan(&w.Name, &w.Count, &w.IsDefault, &w.Family); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, w)
+	}
+
+	return list, nil
+}
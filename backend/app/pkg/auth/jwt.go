@@ -3,10 +3,9 @@ package auth
 import (
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 // type HashType int64
@@ -17,8 +16,9 @@ import (
 // )
 
 type AuthJwtData struct {
-	Email string `json:"email,omitempty"`
-	Id    uint16 `json:"id,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Id        uint16 `json:"id,omitempty"`
+	SessionId uint64 `json:"sid,omitempty"`
 }
 
 type LinkJwtData struct {
@@ -26,24 +26,48 @@ type LinkJwtData struct {
 	// Type HashType
 }
 
+// ChallengeJwtData is proof of a valid password while the second factor
+// is pending. Its claim differs from AuthJwtData, so it is no access token.
+type ChallengeJwtData struct {
+	UserId uint16 `json:"challenge,omitempty"`
+}
+
 type JwtData interface {
-	AuthJwtData | LinkJwtData
+	AuthJwtData | LinkJwtData | ChallengeJwtData
 }
 
 type Jwt[T any] struct {
-	jwt.StandardClaims
+	jwt.RegisteredClaims
 	Data T
 }
 
 type AuthJwt = Jwt[AuthJwtData]
 type LinkJwt = Jwt[LinkJwtData]
+type ChallengeJwt = Jwt[ChallengeJwtData]
+
+// keys signs and verifies all tokens, see UseKeys.
+var keys *KeyManager
+
+// UseKeys sets the keys for Encode and Decode, it is called once on start.
+func UseKeys(manager *KeyManager) {
+	keys = manager
+}
+
+// Keys returns the keys set by UseKeys.
+func Keys() *KeyManager {
+	return keys
+}
 
 func Encode[T JwtData](claims *Jwt[T], expireMins int) (string, error) {
-	claims.StandardClaims.ExpiresAt = time.Now().Add(time.Minute * time.Duration(expireMins)).Unix()
-	claims.StandardClaims.Issuer = "smer-auth"
+	now := time.Now()
+	claims.RegisteredClaims.IssuedAt = jwt.NewNumericDate(now)
+	claims.RegisteredClaims.ExpiresAt = jwt.NewNumericDate(now.Add(time.Minute * time.Duration(expireMins)))
+	claims.RegisteredClaims.Issuer = "smer-auth"
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	tokenString, err := token.SignedString([]byte("secret"))
+	if keys == nil {
+		return "", errors.New("encodeJwt: no signing keys")
+	}
+	tokenString, err := keys.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("encodeJwt: %v", err)
 	}
@@ -51,18 +75,19 @@ func Encode[T JwtData](claims *Jwt[T], expireMins int) (string, error) {
 }
 
 func Decode[T JwtData](claims *Jwt[T], s string) (*jwt.Token, *Jwt[T], error) {
+	if keys == nil {
+		return nil, nil, errors.New("decodeJwt: no signing keys")
+	}
+
 	token, err := jwt.ParseWithClaims(s, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("decodeJwt: unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte("secret"), nil
+		return keys.verifyKey(token)
 	})
 	if err != nil || !token.Valid {
 		if err == nil {
 			err = ErrInvalidToken
 		}
 
-		if strings.Contains(err.Error(), "expired") {
+		if errors.Is(err, jwt.ErrTokenExpired) {
 			return nil, nil, ErrExpiredToken
 		}
 
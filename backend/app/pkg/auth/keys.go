@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AlgorithmHS512 = "HS512"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// minSecretLength is the shortest HS512 secret accepted, 256 bits.
+const minSecretLength = 32
+
+// defaultSecret is the JWT_SECRET of old configs, it must never sign tokens.
+const defaultSecret = "secret"
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeyConfig describes the signing key and the keys it replaced.
+// HS512 keys are secrets, RS256 and EdDSA keys are PEM private key files.
+type KeyConfig struct {
+	Algorithm string
+	KeyId     string
+	Secret    string
+	KeyFile   string
+
+	// PreviousSecrets and PreviousKeyFiles only verify tokens for the
+	// rotation window after the start, so tokens signed before a rotation
+	// stay valid until they expire. An entry is kid=secret (kid=path),
+	// the kid the key signed with, or only the secret (path) when its
+	// id was derived.
+	PreviousSecrets  []string
+	PreviousKeyFiles []string
+	RotationWindow   time.Duration
+}
+
+// Key is a signing key known by its id, the kid header of the tokens.
+type Key struct {
+	Id     string
+	Method jwt.SigningMethod
+	// NotAfter retires a previous key, it verifies nothing from then on.
+	// The current key has none.
+	NotAfter  time.Time
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Public returns the verification key of an asymmetric key, nil for a secret.
+func (k *Key) Public() crypto.PublicKey {
+	if _, ok := k.verifyKey.([]byte); ok {
+		return nil
+	}
+	return k.verifyKey
+}
+
+// KeyManager signs tokens with the current key and verifies them with
+// the current or a previous one that is not retired yet.
+type KeyManager struct {
+	mu       sync.RWMutex
+	current  *Key
+	previous map[string]*Key
+	// now is the server clock keys are retired by.
+	now func() time.Time
+}
+
+// NewKeyManager takes the previous keys with their NotAfter set.
+func NewKeyManager(current *Key, previous ...*Key) *KeyManager {
+	m := &KeyManager{
+		current:  current,
+		previous: make(map[string]*Key, len(previous)),
+		now:      time.Now,
+	}
+	for _, key := range previous {
+		if key.Id != current.Id {
+			m.previous[key.Id] = key
+		}
+	}
+	return m
+}
+
+// LoadKeys builds the key manager from the config. The previous keys are
+// retired once the rotation window from now is over.
+func LoadKeys(cfg KeyConfig) (*KeyManager, error) {
+	var current *Key
+	var err error
+
+	previous := make([]*Key, 0, len(cfg.PreviousSecrets)+len(cfg.PreviousKeyFiles))
+
+	switch cfg.Algorithm {
+	case AlgorithmHS512:
+		if current, err = NewSecretKey(cfg.KeyId, cfg.Secret); err != nil {
+			return nil, err
+		}
+	case AlgorithmRS256, AlgorithmEdDSA:
+		if current, err = LoadKeyFile(cfg.KeyId, cfg.KeyFile); err != nil {
+			return nil, err
+		}
+		if current.Method.Alg() != cfg.Algorithm {
+			return nil, fmt.Errorf("key file %v is not an %v key", cfg.KeyFile, cfg.Algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: '%v'", cfg.Algorithm)
+	}
+
+	for _, entry := range cfg.PreviousSecrets {
+		key, err := NewSecretKey(splitKeyId(entry))
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	for _, entry := range cfg.PreviousKeyFiles {
+		key, err := LoadKeyFile(splitKeyId(entry))
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+
+	notAfter := time.Now().Add(cfg.RotationWindow)
+	for _, key := range previous {
+		key.NotAfter = notAfter
+	}
+
+	return NewKeyManager(current, previous...), nil
+}
+
+// splitKeyId splits a kid=value entry, the id is empty without a '='.
+func splitKeyId(entry string) (string, string) {
+	if id, value, ok := strings.Cut(entry, "="); ok {
+		return id, value
+	}
+	return "", entry
+}
+
+// NewSecretKey makes an HS512 key. Without an id, it is derived from the secret.
+// The default and short secrets are refused, tokens signed with them can be forged.
+func NewSecretKey(id string, secret string) (*Key, error) {
+	if secret == "" {
+		return nil, errors.New("JWT secret is empty")
+	}
+	if secret == defaultSecret {
+		return nil, errors.New("JWT secret is the default one, set JWT_SECRET")
+	}
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("JWT secret is shorter than %d bytes", minSecretLength)
+	}
+	if id == "" {
+		id = keyId([]byte(secret))
+	}
+	return &Key{Id: id, Method: jwt.SigningMethodHS512, signKey: []byte(secret), verifyKey: []byte(secret)}, nil
+}
+
+// LoadKeyFile reads a PEM (PKCS #1 or PKCS #8) RSA or Ed25519 private key.
+// Without an id, it is derived from the public key.
+func LoadKeyFile(id string, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %v", path)
+	}
+
+	var private interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	var key *Key
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key = &Key{Method: jwt.SigningMethodRS256, signKey: private, verifyKey: &private.PublicKey}
+	case ed25519.PrivateKey:
+		key = &Key{Method: jwt.SigningMethodEdDSA, signKey: private, verifyKey: private.Public()}
+	default:
+		return nil, fmt.Errorf("%v: unsupported key type %T", path, private)
+	}
+
+	key.Id = id
+	if key.Id == "" {
+		public, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+		if err != nil {
+			return nil, err
+		}
+		key.Id = keyId(public)
+	}
+
+	return key, nil
+}
+
+func keyId(material []byte) string {
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Keys returns the current key followed by the previous ones not retired yet.
+func (m *KeyManager) Keys() []*Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	keys := make([]*Key, 0, len(m.previous)+1)
+	keys = append(keys, m.current)
+	for _, key := range m.previous {
+		if now.Before(key.NotAfter) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (m *KeyManager) sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	key := m.current
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Id
+	return token.SignedString(key.signKey)
+}
+
+// verifyKey picks the key the token was signed with. Tokens without kid
+// were issued before keys had ids and can only match the current key.
+// Retirement is checked against the server clock, never the token's claims.
+func (m *KeyManager) verifyKey(token *jwt.Token) (interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+
+	key := m.current
+	if kid != "" && kid != m.current.Id {
+		var ok bool
+		if key, ok = m.previous[kid]; !ok {
+			return nil, ErrUnknownKey
+		}
+		if !m.now().Before(key.NotAfter) {
+			return nil, fmt.Errorf("key %v is retired", kid)
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var testSecret = strings.Repeat("s", minSecretLength)
+
+func writeKeyFile(t *testing.T, name string, private interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private
+}
+
+func testEdKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private
+}
+
+func TestNewSecretKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		err    bool
+	}{
+		{"empty", "", true},
+		{"default", defaultSecret, true},
+		{"short", strings.Repeat("s", minSecretLength-1), true},
+		{"long enough", testSecret, false},
+	}
+
+	for _, test := range tests {
+		_, err := NewSecretKey("", test.secret)
+		if (err != nil) != test.err {
+			t.Errorf("%v: NewSecretKey error = %v, want error %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	rsaFile := writeKeyFile(t, "rsa.pem", testRSAKey(t))
+	edFile := writeKeyFile(t, "ed.pem", testEdKey(t))
+
+	tests := []struct {
+		name string
+		cfg  KeyConfig
+		err  bool
+	}{
+		{"secret", KeyConfig{Algorithm: AlgorithmHS512, Secret: testSecret}, false},
+		{"default secret", KeyConfig{Algorithm: AlgorithmHS512, Secret: defaultSecret}, true},
+		{"short previous secret", KeyConfig{Algorithm: AlgorithmHS512, Secret: testSecret, PreviousSecrets: []string{"old=short"}}, true},
+		{"rsa", KeyConfig{Algorithm: AlgorithmRS256, KeyFile: rsaFile}, false},
+		{"eddsa", KeyConfig{Algorithm: AlgorithmEdDSA, KeyFile: edFile}, false},
+		{"algorithm mismatch", KeyConfig{Algorithm: AlgorithmRS256, KeyFile: edFile}, true},
+		{"missing file", KeyConfig{Algorithm: AlgorithmEdDSA, KeyFile: filepath.Join(t.TempDir(), "none.pem")}, true},
+		{"unsupported", KeyConfig{Algorithm: "none"}, true},
+	}
+
+	for _, test := range tests {
+		_, err := LoadKeys(test.cfg)
+		if (err != nil) != test.err {
+			t.Errorf("%v: LoadKeys error = %v, want error %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadKeysRetiresPrevious(t *testing.T) {
+	before := time.Now()
+	manager, err := LoadKeys(KeyConfig{
+		Algorithm:       AlgorithmHS512,
+		KeyId:           "new",
+		Secret:          testSecret,
+		PreviousSecrets: []string{"old=" + strings.Repeat("o", minSecretLength)},
+		RotationWindow:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, ok := manager.previous["old"]
+	if !ok {
+		t.Fatalf("previous keys = %v, want old", manager.previous)
+	}
+	if old.NotAfter.Before(before.Add(time.Hour)) || old.NotAfter.After(time.Now().Add(time.Hour)) {
+		t.Errorf("NotAfter = %v, want an hour from the start", old.NotAfter)
+	}
+	if !manager.current.NotAfter.IsZero() {
+		t.Errorf("current NotAfter = %v, want none", manager.current.NotAfter)
+	}
+}
+
+func TestKeyManagerVerify(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	current, err := NewSecretKey("new", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := NewSecretKey("old", strings.Repeat("o", minSecretLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.NotAfter = start.Add(time.Hour)
+
+	// Tokens signed with the old key before the rotation.
+	oldManager := NewKeyManager(previous)
+	oldToken, err := oldManager.sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A forged iat must not keep a retired key alive.
+	forgedToken, err := oldManager.sign(jwt.RegisteredClaims{Subject: "1", IssuedAt: jwt.NewNumericDate(start.Add(48 * time.Hour))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewKeyManager(current, previous)
+	newToken, err := manager.sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknownKey, err := NewSecretKey("unknown", strings.Repeat("u", minSecretLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownToken, err := NewKeyManager(unknownKey).sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noKid := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.RegisteredClaims{Subject: "1"})
+	noKidToken, err := noKid.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		valid bool
+	}{
+		{"current", newToken, start, true},
+		{"current after the window", newToken, start.Add(48 * time.Hour), true},
+		{"without kid", noKidToken, start, true},
+		{"previous within the window", oldToken, start.Add(time.Minute), true},
+		{"previous at retirement", oldToken, start.Add(time.Hour), false},
+		{"previous after the window", oldToken, start.Add(2 * time.Hour), false},
+		{"previous with a forged iat", forgedToken, start.Add(2 * time.Hour), false},
+		{"unknown kid", unknownToken, start, false},
+	}
+
+	for _, test := range tests {
+		manager.now = func() time.Time { return test.now }
+
+		_, err := jwt.Parse(test.token, manager.verifyKey, jwt.WithoutClaimsValidation())
+		if (err == nil) != test.valid {
+			t.Errorf("%v: Parse error = %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestKeyManagerVerifyUnknownKey(t *testing.T) {
+	current, err := NewSecretKey("new", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewKeyManager(current)
+
+	token := &jwt.Token{Header: map[string]interface{}{"kid": "other"}, Method: jwt.SigningMethodHS512}
+	if _, err := manager.verifyKey(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("verifyKey error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	rsaPrivate := testRSAKey(t)
+	current, err := LoadKeyFile("rsa", writeKeyFile(t, "rsa.pem", rsaPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPrivate := testEdKey(t)
+	ed, err := LoadKeyFile("ed", writeKeyFile(t, "ed.pem", edPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed.NotAfter = start.Add(time.Hour)
+
+	secret, err := NewSecretKey("secret", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.NotAfter = start.Add(time.Hour)
+
+	manager := NewKeyManager(current, ed, secret)
+
+	rsaJWK := JWK{
+		Kty: "RSA", Use: "sig", Alg: AlgorithmRS256, Kid: "rsa",
+		N: base64.RawURLEncoding.EncodeToString(rsaPrivate.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaPrivate.E)).Bytes()),
+	}
+	edJWK := JWK{
+		Kty: "OKP", Use: "sig", Alg: AlgorithmEdDSA, Kid: "ed",
+		Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(edPrivate.Public().(ed25519.PublicKey)),
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want []JWK
+	}{
+		{"within the window", start, []JWK{rsaJWK, edJWK}},
+		{"after the window", start.Add(time.Hour), []JWK{rsaJWK}},
+	}
+
+	for _, test := range tests {
+		manager.now = func() time.Time { return test.now }
+
+		got := manager.JWKS().Keys
+		if len(got) != len(test.want) {
+			t.Errorf("%v: JWKS = %+v, want %+v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%v: JWKS key %d = %+v, want %+v", test.name, i, got[i], test.want[i])
+			}
+		}
+	}
+}
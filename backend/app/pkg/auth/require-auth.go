@@ -5,6 +5,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -18,7 +19,28 @@ func RequireAuth(next httprouter.Handle) httprouter.Handle {
 			utils.WriteErrorResponse(w, http.StatusForbidden, err.Error())
 			return
 		}
-		r = r.WithContext(context.WithValue(r.Context(), "userId", claims.Data.Id))
+		if claims.Data.Id == 0 {
+			utils.WriteErrorResponse(w, http.StatusForbidden, ErrInvalidToken.Error())
+			return
+		}
+		if revocations != nil {
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			revoked, err := revocations.Revoked(claims.Data.Id, claims.Data.SessionId, issuedAt)
+			if err != nil {
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if revoked {
+				utils.WriteErrorResponse(w, http.StatusForbidden, ErrRevokedToken.Error())
+				return
+			}
+		}
+		ctx := context.WithValue(r.Context(), "userId", claims.Data.Id)
+		ctx = context.WithValue(ctx, "sessionId", claims.Data.SessionId)
+		r = r.WithContext(ctx)
 		next(w, r, ps)
 	}
 }
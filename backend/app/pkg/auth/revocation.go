@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrRevokedToken = errors.New("Token revoked")
+
+// RevocationChecker tells whether a valid token was revoked by a logout.
+type RevocationChecker interface {
+	Revoked(userId uint16, sessionId uint64, issuedAt time.Time) (bool, error)
+}
+
+// revocations is consulted by RequireAuth, see UseRevocations.
+var revocations RevocationChecker
+
+// UseRevocations makes RequireAuth reject revoked tokens, it is called once on start.
+func UseRevocations(checker RevocationChecker) {
+	revocations = checker
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// FieldText Текстовая колонка
+	FieldText FieldType = iota
+
+	// FieldArray Колонка TEXT[]
+	FieldArray
+
+	// FieldTime Колонка timestamptz, значения в RFC 3339 или YYYY-MM-DD
+	FieldTime
+
+	// FieldNumber Целочисленная колонка
+	FieldNumber
+)
+
+const (
+	maxFilters     = 20
+	maxFilterDepth = 3
+)
+
+// ErrInvalidFilter Фильтр из запроса не прошёл проверку
+var ErrInvalidFilter = errors.New("invalid filter")
+
+type (
+	FieldType uint8
+
+	// Field Колонка, доступная для фильтрации
+	Field struct {
+		Column string
+		Type   FieldType
+	}
+
+	// Fields Белый список колонок ресурса, ключ - имя колонки в API
+	Fields map[string]Field
+)
+
+// filterOps Операторы, допустимые для каждого типа колонки
+var filterOps = map[FieldType]map[string]FilterType{
+	FieldText: {
+		"eq": FilterTypeEQ, "neq": FilterTypeNotEQ,
+		"like": FilterTypeLike, "nlike": FilterTypeNotLike,
+		"ilike": FilterTypeILike, "nilike": FilterTypeNotILike,
+	},
+	FieldArray: {
+		"contains": FilterTypeContains, "overlaps": FilterTypeOverlaps, "ilike": FilterTypeAnyILike,
+	},
+	FieldTime: {
+		"eq": FilterTypeEQ, "neq": FilterTypeNotEQ,
+		"gt": FilterTypeGT, "gte": FilterTypeGTE, "lt": FilterTypeLT, "lte": FilterTypeLTE,
+	},
+	FieldNumber: {
+		"eq": FilterTypeEQ, "neq": FilterTypeNotEQ,
+		"gt": FilterTypeGT, "gte": FilterTypeGTE, "lt": FilterTypeLT, "lte": FilterTypeLTE,
+	},
+}
+
+// filter Создание фильтра по колонке с проверкой оператора и значений.
+// between для дат и чисел раскрывается в gte первого и lt второго значения.
+func (f Field) filter(op string, values []string) (*Filter, error) {
+	if op == "between" && (f.Type == FieldTime || f.Type == FieldNumber) {
+		if len(values) != 2 {
+			return nil, errors.New("'between' needs exactly two values")
+		}
+		from, err := f.parse(values[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := f.parse(values[1])
+		if err != nil {
+			return nil, err
+		}
+		return NewFilterGroup(OperatorAnd,
+			*NewFilter(f.Column, FilterTypeGTE, from),
+			*NewFilter(f.Column, FilterTypeLT, to),
+		), nil
+	}
+
+	fType, ok := filterOps[f.Type][op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported operator '%v'", op)
+	}
+
+	if fType == FilterTypeContains || fType == FilterTypeOverlaps {
+		if len(values) == 0 {
+			return nil, errors.New("no values")
+		}
+		return NewFilter(f.Column, fType, values), nil
+	}
+
+	if len(values) != 1 {
+		return nil, fmt.Errorf("'%v' needs exactly one value", op)
+	}
+	value, err := f.parse(values[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewFilter(f.Column, fType, value), nil
+}
+
+func (f Field) parse(value string) (any, error) {
+	switch f.Type {
+	case FieldTime:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse("2006-01-02", value); err == nil {
+			return t, nil
+		}
+		return nil, fmt.Errorf("invalid date '%v'", value)
+	case FieldNumber:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%v'", value)
+		}
+		return n, nil
+	}
+	return value, nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/Masterminds/squirrel"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 const (
@@ -39,6 +41,15 @@ const (
 
 	FilterTypePage
 	FilterTypeLimit
+
+	// FilterTypeContains Массив содержит все значения
+	FilterTypeContains
+
+	// FilterTypeOverlaps Массив содержит хотя бы одно из значений
+	FilterTypeOverlaps
+
+	// FilterTypeAnyILike Хотя бы один элемент массива содержит значение (регистронезависимый)
+	FilterTypeAnyILike
 )
 
 const (
@@ -57,29 +68,101 @@ type (
 		value    any
 		operator Operator
 		filters  []Filter
+		expr     squirrel.Sqlizer
 	}
 )
 
-func NewFilters(r *http.Request) ([]*Filter, error) {
+// NewFilters Разбор фильтров из query string, колонки проверяются по списку fields.
+//
+//	filter[0][column]=situation&filter[0][op]=ilike&filter[0][value]=работа
+//	filter[1][or][0][column]=emotions&filter[1][or][0][op]=contains&filter[1][or][0][value]=страх
+//	filter[1][or][1][column]=createdAt&filter[1][or][1][op]=between&filter[1][or][1][value]=2026-01-01&filter[1][or][1][value]=2026-02-01
+//
+// Без op используется ilike. Ошибка оборачивает ErrInvalidFilter.
+func NewFilters(r *http.Request, fields Fields) ([]*Filter, error) {
 	queryValues := r.URL.Query()
 
-	var filters []*Filter
-	for i := 0; ; i++ {
-		keyColumn := fmt.Sprintf("filter[%d][column]", i)
-		column := queryValues.Get(keyColumn)
+	filters, err := parseFilters(queryValues, "filter", fields, 0)
+	if err != nil {
+		return nil, err
+	}
 
-		keyValue := fmt.Sprintf("filter[%d][value]", i)
-		value := queryValues.Get(keyValue)
+	result := make([]*Filter, 0, len(filters))
+	for i := range filters {
+		result = append(result, &filters[i])
+	}
+	return result, nil
+}
+
+func parseFilters(queryValues url.Values, prefix string, fields Fields, depth int) ([]Filter, error) {
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("%w: %v: groups are nested too deep", ErrInvalidFilter, prefix)
+	}
 
-		if column == "" {
-			break
+	var filters []Filter
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("%v[%d]", prefix, i)
+
+		var filter *Filter
+		var err error
+		switch {
+		case queryValues.Has(key + "[column]"):
+			filter, err = parseFilter(queryValues, key, fields)
+		case hasPrefix(queryValues, key+"[or]["):
+			filter, err = parseFilterGroup(queryValues, key+"[or]", OperatorOr, fields, depth)
+		case hasPrefix(queryValues, key+"[and]["):
+			filter, err = parseFilterGroup(queryValues, key+"[and]", OperatorAnd, fields, depth)
+		default:
+			return filters, nil
+		}
+		if err != nil {
+			return nil, err
 		}
 
-		// TODO fix ilike
-		filters = append(filters, NewFilter(column, FilterTypeILike, value))
+		if len(filters) == maxFilters {
+			return nil, fmt.Errorf("%w: %v: too many filters", ErrInvalidFilter, prefix)
+		}
+		filters = append(filters, *filter)
 	}
+}
 
-	return filters, nil
+func parseFilterGroup(queryValues url.Values, prefix string, operator Operator, fields Fields, depth int) (*Filter, error) {
+	filters, err := parseFilters(queryValues, prefix, fields, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("%w: %v: empty group", ErrInvalidFilter, prefix)
+	}
+	return NewFilterGroup(operator, filters...), nil
+}
+
+func parseFilter(queryValues url.Values, prefix string, fields Fields) (*Filter, error) {
+	name := queryValues.Get(prefix + "[column]")
+	field, ok := fields[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v: unknown column '%v'", ErrInvalidFilter, prefix, name)
+	}
+
+	op := queryValues.Get(prefix + "[op]")
+	if op == "" {
+		op = "ilike"
+	}
+
+	filter, err := field.filter(op, queryValues[prefix+"[value]"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v: column '%v': %v", ErrInvalidFilter, prefix, name, err)
+	}
+	return filter, nil
+}
+
+func hasPrefix(queryValues url.Values, prefix string) bool {
+	for key := range queryValues {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // NewFilter Создание нового фильтра
@@ -93,6 +176,23 @@ func NewFilter(column string, ftype FilterType, value any) *Filter {
 	}
 }
 
+// NewFilterGroup Создание группы фильтров без собственного условия
+func NewFilterGroup(operator Operator, filters ...Filter) *Filter {
+	return &Filter{
+		operator: operator,
+		filters:  filters,
+	}
+}
+
+// NewExprFilter Создание фильтра из произвольного условия (например, подзапроса)
+func NewExprFilter(expr squirrel.Sqlizer) *Filter {
+	return &Filter{
+		expr:     expr,
+		operator: OperatorAnd,
+		filters:  make([]Filter, 0),
+	}
+}
+
 // SetOperator Установка оператора для связывания всех дополнительных фильтров
 func (f *Filter) SetOperator(operator Operator) *Filter {
 	f.operator = operator
@@ -106,6 +206,10 @@ func (f *Filter) WithFilters(filters ...Filter) *Filter {
 }
 
 func (f Filter) condition() squirrel.Sqlizer {
+	if f.expr != nil {
+		return f.expr
+	}
+
 	switch f.fType {
 	case FilterTypeNotEQ:
 		return squirrel.NotEq{f.column: f.value}
@@ -124,7 +228,16 @@ func (f Filter) condition() squirrel.Sqlizer {
 	case FilterTypeILike:
 		return squirrel.ILike{f.column: fmt.Sprintf("%v%v%v", "%", f.value, "%")}
 	case FilterTypeNotILike:
-		return squirrel.NotILike{f.column: f.value}
+		return squirrel.NotILike{f.column: fmt.Sprintf("%v%v%v", "%", f.value, "%")}
+	case FilterTypeContains:
+		return squirrel.Expr(f.column+" @> ?::text[]", f.value)
+	case FilterTypeOverlaps:
+		return squirrel.Expr(f.column+" && ?::text[]", f.value)
+	case FilterTypeAnyILike:
+		return squirrel.Expr(
+			"EXISTS (SELECT 1 FROM unnest("+f.column+") AS item WHERE item ILIKE ?)",
+			fmt.Sprintf("%v%v%v", "%", f.value, "%"),
+		)
 	case FilterTypeEQ:
 	default:
 	}
@@ -132,6 +245,11 @@ func (f Filter) condition() squirrel.Sqlizer {
 	return squirrel.Eq{f.column: f.value}
 }
 
+// isGroup Фильтр только объединяет дополнительные фильтры
+func (f Filter) isGroup() bool {
+	return f.column == "" && f.expr == nil
+}
+
 func (f Filter) getConditions() squirrel.Sqlizer {
 	if len(f.filters) == 0 {
 		return f.condition()
@@ -139,7 +257,9 @@ func (f Filter) getConditions() squirrel.Sqlizer {
 
 	var conditions []squirrel.Sqlizer
 
-	conditions = append(conditions, f.condition())
+	if !f.isGroup() {
+		conditions = append(conditions, f.condition())
+	}
 
 	for _, filter := range f.filters {
 		conditions = append(conditions, filter.getConditions())
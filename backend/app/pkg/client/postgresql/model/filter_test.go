@@ -0,0 +1,141 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+var testFields = Fields{
+	"situation": {Column: "situation", Type: FieldText},
+	"emotions":  {Column: "emotions", Type: FieldArray},
+	"createdAt": {Column: "created_at", Type: FieldTime},
+	"id":        {Column: "id", Type: FieldNumber},
+}
+
+func TestNewFilters(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query string
+		sql   string
+		args  []any
+	}{
+		{"none", "", "SELECT * FROM smers", nil},
+		{
+			"default ilike",
+			"filter[0][column]=situation&filter[0][value]=work",
+			"SELECT * FROM smers WHERE situation ILIKE ?",
+			[]any{"%work%"},
+		},
+		{
+			"number",
+			"filter[0][column]=id&filter[0][op]=gte&filter[0][value]=10",
+			"SELECT * FROM smers WHERE id >= ?",
+			[]any{int64(10)},
+		},
+		{
+			"contains",
+			"filter[0][column]=emotions&filter[0][op]=contains&filter[0][value]=fear&filter[0][value]=anger",
+			"SELECT * FROM smers WHERE emotions @> ?::text[]",
+			[]any{[]string{"fear", "anger"}},
+		},
+		{
+			"between",
+			"filter[0][column]=createdAt&filter[0][op]=between&filter[0][value]=2026-01-01&filter[0][value]=2026-02-01",
+			"SELECT * FROM smers WHERE (created_at >= ? AND created_at < ?)",
+			[]any{day, day.AddDate(0, 1, 0)},
+		},
+		{
+			"several",
+			"filter[0][column]=situation&filter[0][op]=eq&filter[0][value]=work&" +
+				"filter[1][column]=createdAt&filter[1][op]=lt&filter[1][value]=2026-01-01T00:00:00Z",
+			"SELECT * FROM smers WHERE situation = ? AND created_at < ?",
+			[]any{"work", day},
+		},
+		{
+			"or group",
+			"filter[0][or][0][column]=emotions&filter[0][or][0][op]=overlaps&filter[0][or][0][value]=fear&" +
+				"filter[0][or][1][column]=situation&filter[0][or][1][op]=nilike&filter[0][or][1][value]=home",
+			"SELECT * FROM smers WHERE (emotions && ?::text[] OR situation NOT ILIKE ?)",
+			[]any{[]string{"fear"}, "%home%"},
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/smers?"+test.query, nil)
+
+		filters, err := NewFilters(r, testFields)
+		if err != nil {
+			t.Errorf("%v: NewFilters error = %v", test.name, err)
+			continue
+		}
+
+		query := squirrel.Select("*").From("smers")
+		for _, filter := range filters {
+			query = filter.UseSelectBuilder(query)
+		}
+		sql, args, err := query.ToSql()
+		if err != nil {
+			t.Errorf("%v: ToSql error = %v", test.name, err)
+			continue
+		}
+
+		if sql != test.sql {
+			t.Errorf("%v: sql = %q, want %q", test.name, sql, test.sql)
+		}
+		if len(args) != 0 || len(test.args) != 0 {
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("%v: args = %v, want %v", test.name, args, test.args)
+			}
+		}
+	}
+}
+
+func TestNewFiltersInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown column", "filter[0][column]=password&filter[0][value]=x"},
+		{"column name instead of api name", "filter[0][column]=created_at&filter[0][op]=gt&filter[0][value]=2026-01-01"},
+		{"operator of another type", "filter[0][column]=situation&filter[0][op]=gt&filter[0][value]=a"},
+		{"unknown operator", "filter[0][column]=id&filter[0][op]=drop&filter[0][value]=1"},
+		{"invalid date", "filter[0][column]=createdAt&filter[0][op]=gt&filter[0][value]=yesterday"},
+		{"invalid number", "filter[0][column]=id&filter[0][op]=eq&filter[0][value]=ten"},
+		{"no value", "filter[0][column]=id&filter[0][op]=eq"},
+		{"two values", "filter[0][column]=id&filter[0][op]=eq&filter[0][value]=1&filter[0][value]=2"},
+		{"between one value", "filter[0][column]=id&filter[0][op]=between&filter[0][value]=1"},
+		{"array without values", "filter[0][column]=emotions&filter[0][op]=contains"},
+		{"invalid in group", "filter[0][or][0][column]=situation&filter[0][or][1][column]=unknown"},
+		{"too deep", "filter[0][or][0][and][0][or][0][and][0][column]=situation&filter[0][or][0][and][0][or][0][and][0][value]=a"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/smers?"+test.query, nil)
+
+		if _, err := NewFilters(r, testFields); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%v: NewFilters error = %v, want ErrInvalidFilter", test.name, err)
+		}
+	}
+}
+
+func TestNewFiltersLimit(t *testing.T) {
+	values := make([]string, 0, maxFilters+1)
+	for i := 0; i <= maxFilters; i++ {
+		key := fmt.Sprintf("filter[%d]", i)
+		values = append(values, key+"[column]=situation&"+key+"[value]=a")
+	}
+
+	r := httptest.NewRequest("GET", "/api/smers?"+strings.Join(values, "&"), nil)
+	if _, err := NewFilters(r, testFields); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("NewFilters with %d filters error = %v, want ErrInvalidFilter", maxFilters+1, err)
+	}
+}
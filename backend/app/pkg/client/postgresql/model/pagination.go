@@ -1,21 +1,47 @@
 package model
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Masterminds/squirrel"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+// ErrInvalidCursor Курсор из запроса не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Pagination struct {
 	Page  uint64
 	Limit uint64
+
+	// Keyset Постраничный вывод по курсору вместо LIMIT/OFFSET
+	Keyset bool
+	// Cursor Граница предыдущей выборки, nil - первая страница
+	Cursor *Cursor
+	// Count Нужно ли считать общее количество записей
+	Count bool
+}
+
+// Cursor Значения колонок сортировки (последняя - id) у граничной записи.
+// Sort хранит саму сортировку, курсор от другой сортировки не принимается.
+type Cursor struct {
+	Sort     []string `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
 }
 
+// NewPagination Разбор параметров page, limit, cursor и count.
+// Параметр cursor (даже пустой) включает выборку по курсору, в ней
+// по умолчанию не считается общее количество записей.
 func NewPagination(r *http.Request) (*Pagination, error) {
 	queryValues := r.URL.Query()
 
 	page, err := strconv.ParseUint(queryValues.Get("page"), 10, 64)
-	if err != nil {
+	if err != nil || page == 0 {
 		page = 1
 	}
 	limit, err := strconv.ParseUint(queryValues.Get("limit"), 10, 64)
@@ -23,12 +49,162 @@ func NewPagination(r *http.Request) (*Pagination, error) {
 		limit = 10
 	}
 
-	return &Pagination{
-		Page:  page,
-		Limit: limit,
-	}, nil
+	pagination := &Pagination{
+		Page:   page,
+		Limit:  limit,
+		Keyset: queryValues.Has("cursor"),
+	}
+	pagination.Count = !pagination.Keyset
+
+	if count := queryValues.Get("count"); count != "" {
+		if pagination.Count, err = strconv.ParseBool(count); err != nil {
+			return nil, fmt.Errorf("invalid count: '%v'", count)
+		}
+	}
+
+	if cursor := queryValues.Get("cursor"); cursor != "" {
+		if pagination.Cursor, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	return pagination, nil
+}
+
+// Pages Количество страниц для total записей, без лимита - ноль
+func (opt Pagination) Pages(total uint64) uint64 {
+	if opt.Limit == 0 {
+		return 0
+	}
+	return (total + opt.Limit - 1) / opt.Limit
 }
 
 func (opt Pagination) UseSelectBuilder(builder squirrel.SelectBuilder) squirrel.SelectBuilder {
 	return builder.Limit(opt.Limit).Offset(opt.Limit * (opt.Page - 1))
 }
+
+// UseKeysetBuilder Сортировка и условие выборки по курсору. Последней в sorts
+// должна идти уникальная колонка (id), значения колонок не должны быть NULL.
+// Выбирается на одну запись больше Limit, чтобы узнать, есть ли следующая страница.
+// При движении назад порядок обратный, записи нужно развернуть (см. Cursors).
+func (opt Pagination) UseKeysetBuilder(builder squirrel.SelectBuilder, sorts []*Sort) (squirrel.SelectBuilder, error) {
+	backward := opt.Cursor != nil && opt.Cursor.Backward
+
+	if opt.Cursor != nil {
+		if len(opt.Cursor.Values) != len(sorts) || !equalKeys(opt.Cursor.Sort, sortKeys(sorts)) {
+			return builder, fmt.Errorf("%w: it doesn't match the sort", ErrInvalidCursor)
+		}
+
+		// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ..., так работают и разные направления сортировки
+		after := squirrel.Or{}
+		for i, sort := range sorts {
+			condition := squirrel.And{}
+			for j := 0; j < i; j++ {
+				condition = append(condition, squirrel.Eq{sorts[j].column: opt.Cursor.Values[j]})
+			}
+			if (sort.order == "DESC") != backward {
+				condition = append(condition, squirrel.Lt{sort.column: opt.Cursor.Values[i]})
+			} else {
+				condition = append(condition, squirrel.Gt{sort.column: opt.Cursor.Values[i]})
+			}
+			after = append(after, condition)
+		}
+		builder = builder.Where(after)
+	}
+
+	for _, sort := range sorts {
+		if backward {
+			sort = sort.reversed()
+		}
+		builder = sort.UseSelectBuilder(builder)
+	}
+
+	return builder.Limit(opt.Limit + 1), nil
+}
+
+// Cursors Курсоры соседних страниц. sorts - те же, что в UseKeysetBuilder,
+// fetched - сколько записей вернул запрос, first и last - значения колонок
+// сортировки у первой и последней записи страницы (уже в прямом порядке).
+func (opt Pagination) Cursors(sorts []*Sort, fetched int, first, last []any) (next *string, prev *string) {
+	if fetched == 0 {
+		return nil, nil
+	}
+
+	more := uint64(fetched) > opt.Limit
+	backward := opt.Cursor != nil && opt.Cursor.Backward
+
+	keys := sortKeys(sorts)
+	if more || backward {
+		next = encodeCursor(Cursor{Sort: keys, Values: cursorValues(last)})
+	}
+	if (more && backward) || (opt.Cursor != nil && !backward) {
+		prev = encodeCursor(Cursor{Sort: keys, Values: cursorValues(first), Backward: true})
+	}
+	return next, prev
+}
+
+// sortKeys Колонки и направления сортировки для сравнения с курсором
+func sortKeys(sorts []*Sort) []string {
+	keys := make([]string, len(sorts))
+	for i, sort := range sorts {
+		keys[i] = sort.column + " " + sort.order
+	}
+	return keys
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func cursorValues(values []any) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case time.Time:
+			result[i] = v.Format(time.RFC3339Nano)
+		default:
+			result[i] = fmt.Sprint(v)
+		}
+	}
+	return result
+}
+
+func encodeCursor(cursor Cursor) *string {
+	data, _ := json.Marshal(cursor)
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return &encoded
+}
+
+func decodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err = json.Unmarshal(data, &cursor); err != nil || len(cursor.Values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// KeysetPage Отбрасывает лишнюю запись из UseKeysetBuilder и возвращает прямой порядок
+func KeysetPage[T any](opt Pagination, rows []T) []T {
+	if uint64(len(rows)) > opt.Limit {
+		rows = rows[:opt.Limit]
+	}
+	if opt.Cursor != nil && opt.Cursor.Backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	return rows
+}
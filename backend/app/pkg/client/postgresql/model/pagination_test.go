@@ -0,0 +1,171 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+func testSorts() []*Sort {
+	return []*Sort{NewSort("created_at", "desc"), NewSort("id", "desc")}
+}
+
+func decodedCursor(t *testing.T, encoded *string) *Cursor {
+	t.Helper()
+	if encoded == nil {
+		return nil
+	}
+	decoded, err := decodeCursor(*encoded)
+	if err != nil {
+		t.Fatalf("decodeCursor(%v) error = %v", *encoded, err)
+	}
+	return decoded
+}
+
+func TestCursors(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 500, time.UTC)
+	first := []any{at, uint16(9)}
+	last := []any{at.Add(-time.Hour), uint16(5)}
+	keys := []string{"created_at DESC", "id DESC"}
+
+	firstValues := []string{"2026-01-01T12:00:00.0000005Z", "9"}
+	lastValues := []string{"2026-01-01T11:00:00.0000005Z", "5"}
+
+	tests := []struct {
+		name    string
+		cursor  *Cursor
+		fetched int
+		next    *Cursor
+		prev    *Cursor
+	}{
+		{"empty", nil, 0, nil, nil},
+		{"only page", nil, 2, nil, nil},
+		{"first of several", nil, 3, &Cursor{Sort: keys, Values: lastValues}, nil},
+		{
+			"middle",
+			&Cursor{Sort: keys, Values: []string{"x", "1"}}, 3,
+			&Cursor{Sort: keys, Values: lastValues},
+			&Cursor{Sort: keys, Values: firstValues, Backward: true},
+		},
+		{
+			"last",
+			&Cursor{Sort: keys, Values: []string{"x", "1"}}, 2,
+			nil,
+			&Cursor{Sort: keys, Values: firstValues, Backward: true},
+		},
+		{
+			"back to the first",
+			&Cursor{Sort: keys, Values: []string{"x", "1"}, Backward: true}, 2,
+			&Cursor{Sort: keys, Values: lastValues},
+			nil,
+		},
+		{
+			"back in the middle",
+			&Cursor{Sort: keys, Values: []string{"x", "1"}, Backward: true}, 3,
+			&Cursor{Sort: keys, Values: lastValues},
+			&Cursor{Sort: keys, Values: firstValues, Backward: true},
+		},
+	}
+
+	for _, test := range tests {
+		opt := Pagination{Limit: 2, Keyset: true, Cursor: test.cursor}
+
+		next, prev := opt.Cursors(testSorts(), test.fetched, first, last)
+		if got := decodedCursor(t, next); !reflect.DeepEqual(got, test.next) {
+			t.Errorf("%v: next = %+v, want %+v", test.name, got, test.next)
+		}
+		if got := decodedCursor(t, prev); !reflect.DeepEqual(got, test.prev) {
+			t.Errorf("%v: prev = %+v, want %+v", test.name, got, test.prev)
+		}
+	}
+}
+
+func TestKeysetPage(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor *Cursor
+		rows   []int
+		want   []int
+	}{
+		{"empty", nil, []int{}, []int{}},
+		{"short", nil, []int{1, 2}, []int{1, 2}},
+		{"extra row dropped", nil, []int{1, 2, 3}, []int{1, 2}},
+		{"forward", &Cursor{Values: []string{"1"}}, []int{1, 2, 3}, []int{1, 2}},
+		{"backward reversed", &Cursor{Values: []string{"1"}, Backward: true}, []int{3, 2, 1}, []int{2, 3}},
+		{"backward short", &Cursor{Values: []string{"1"}, Backward: true}, []int{2, 1}, []int{1, 2}},
+	}
+
+	for _, test := range tests {
+		opt := Pagination{Limit: 2, Keyset: true, Cursor: test.cursor}
+		if got := KeysetPage(opt, test.rows); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: KeysetPage = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUseKeysetBuilder(t *testing.T) {
+	keys := []string{"created_at DESC", "id DESC"}
+
+	tests := []struct {
+		name   string
+		cursor *Cursor
+		sql    string
+		args   []any
+		err    bool
+	}{
+		{
+			"first page", nil,
+			"SELECT * FROM smers ORDER BY created_at DESC, id DESC LIMIT 3",
+			nil, false,
+		},
+		{
+			"forward", &Cursor{Sort: keys, Values: []string{"t", "5"}},
+			"SELECT * FROM smers WHERE ((created_at < ?) OR (created_at = ? AND id < ?)) " +
+				"ORDER BY created_at DESC, id DESC LIMIT 3",
+			[]any{"t", "t", "5"}, false,
+		},
+		{
+			"backward", &Cursor{Sort: keys, Values: []string{"t", "5"}, Backward: true},
+			"SELECT * FROM smers WHERE ((created_at > ?) OR (created_at = ? AND id > ?)) " +
+				"ORDER BY created_at ASC, id ASC LIMIT 3",
+			[]any{"t", "t", "5"}, false,
+		},
+		{"fewer values", &Cursor{Sort: keys[:1], Values: []string{"t"}}, "", nil, true},
+		{"another order", &Cursor{Sort: []string{"created_at ASC", "id DESC"}, Values: []string{"t", "5"}}, "", nil, true},
+		{"another column", &Cursor{Sort: []string{"updated_at DESC", "id DESC"}, Values: []string{"t", "5"}}, "", nil, true},
+		{"no sort", &Cursor{Values: []string{"t", "5"}}, "", nil, true},
+	}
+
+	for _, test := range tests {
+		opt := Pagination{Limit: 2, Keyset: true, Cursor: test.cursor}
+
+		query, err := opt.UseKeysetBuilder(squirrel.Select("*").From("smers"), testSorts())
+		if test.err {
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("%v: UseKeysetBuilder error = %v, want ErrInvalidCursor", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: UseKeysetBuilder error = %v", test.name, err)
+			continue
+		}
+
+		sql, args, err := query.ToSql()
+		if err != nil {
+			t.Errorf("%v: ToSql error = %v", test.name, err)
+			continue
+		}
+		if sql != test.sql {
+			t.Errorf("%v: sql = %q, want %q", test.name, sql, test.sql)
+		}
+		if len(args) != 0 || len(test.args) != 0 {
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("%v: args = %v, want %v", test.name, args, test.args)
+			}
+		}
+	}
+}
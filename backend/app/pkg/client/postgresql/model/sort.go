@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -8,27 +9,58 @@ import (
 	"github.com/Masterminds/squirrel"
 )
 
+const maxSorts = 5
+
+// ErrInvalidSort Сортировка из запроса не прошла проверку
+var ErrInvalidSort = errors.New("invalid sort")
+
 type Sort struct {
 	column string
 	order  string
+	nulls  string
 }
 
-func NewSorts(r *http.Request) ([]*Sort, error) {
+// NewSorts Разбор сортировок из query string, колонки проверяются по списку fields.
+//
+//	sort[0][column]=createdAt&sort[0][order]=desc&sort[0][nulls]=last
+//
+// Ошибка оборачивает ErrInvalidSort.
+func NewSorts(r *http.Request, fields Fields) ([]*Sort, error) {
 	queryValues := r.URL.Query()
 
 	var sorts []*Sort
 	for i := 0; ; i++ {
 		keyColumn := fmt.Sprintf("sort[%d][column]", i)
-		column := queryValues.Get(keyColumn)
+		name := queryValues.Get(keyColumn)
 
 		keyOrder := fmt.Sprintf("sort[%d][order]", i)
-		order := queryValues.Get(keyOrder)
+		order := strings.ToUpper(queryValues.Get(keyOrder))
 
-		if column == "" {
+		keyNulls := fmt.Sprintf("sort[%d][nulls]", i)
+		nulls := strings.ToUpper(queryValues.Get(keyNulls))
+
+		if name == "" {
 			break
 		}
+		if i == maxSorts {
+			return nil, fmt.Errorf("%w: too many columns", ErrInvalidSort)
+		}
 
-		sorts = append(sorts, NewSort(column, order))
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: sort[%d]: unknown column '%v'", ErrInvalidSort, i, name)
+		}
+		if field.Type == FieldArray {
+			return nil, fmt.Errorf("%w: sort[%d]: column '%v' is not sortable", ErrInvalidSort, i, name)
+		}
+		if order != "" && order != "ASC" && order != "DESC" {
+			return nil, fmt.Errorf("%w: sort[%d]: order should be 'asc' or 'desc'", ErrInvalidSort, i)
+		}
+		if nulls != "" && nulls != "FIRST" && nulls != "LAST" {
+			return nil, fmt.Errorf("%w: sort[%d]: nulls should be 'first' or 'last'", ErrInvalidSort, i)
+		}
+
+		sorts = append(sorts, NewSort(field.Column, order).NullsAt(nulls))
 	}
 
 	return sorts, nil
@@ -46,6 +78,54 @@ func NewSort(column, order string) *Sort {
 	}
 }
 
+// WithTiebreaker Дополнение сортировок: без них используется fallback,
+// последней всегда идёт уникальная колонка id (в направлении последней
+// сортировки), чтобы порядок страниц и курсоры были стабильными.
+func WithTiebreaker(sorts []*Sort, fallback *Sort, id string) []*Sort {
+	if len(sorts) == 0 {
+		sorts = []*Sort{fallback}
+	}
+
+	last := sorts[len(sorts)-1]
+	if last.column == id {
+		return sorts
+	}
+	return append(sorts[:len(sorts):len(sorts)], NewSort(id, last.order))
+}
+
+// NullsAt Положение NULL в сортировке: FIRST, LAST или по умолчанию для направления
+func (opt *Sort) NullsAt(nulls string) *Sort {
+	opt.nulls = strings.ToUpper(nulls)
+	return opt
+}
+
+func (opt Sort) Column() string {
+	return opt.column
+}
+
+func (opt Sort) Order() string {
+	return opt.order
+}
+
+// reversed Та же колонка в обратном порядке
+func (opt Sort) reversed() *Sort {
+	order := "ASC"
+	if opt.order == "ASC" {
+		order = "DESC"
+	}
+	nulls := opt.nulls
+	switch nulls {
+	case "FIRST":
+		nulls = "LAST"
+	case "LAST":
+		nulls = "FIRST"
+	}
+	return &Sort{column: opt.column, order: order, nulls: nulls}
+}
+
 func (opt Sort) UseSelectBuilder(builder squirrel.SelectBuilder) squirrel.SelectBuilder {
+	if opt.nulls != "" {
+		return builder.OrderBy(opt.column + " " + opt.order + " NULLS " + opt.nulls)
+	}
 	return builder.OrderBy(opt.column + " " + opt.order)
 }
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithTiebreaker(t *testing.T) {
+	fallback := NewSort("created_at", "desc")
+
+	tests := []struct {
+		name  string
+		sorts []*Sort
+		want  []*Sort
+	}{
+		{"fallback", nil, []*Sort{fallback, NewSort("id", "desc")}},
+		{"appended", []*Sort{NewSort("email", "asc")}, []*Sort{NewSort("email", "asc"), NewSort("id", "asc")}},
+		{"already last", []*Sort{NewSort("email", "asc"), NewSort("id", "desc")}, []*Sort{NewSort("email", "asc"), NewSort("id", "desc")}},
+	}
+
+	for _, test := range tests {
+		if got := WithTiebreaker(test.sorts, fallback, "id"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: WithTiebreaker = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Kind names jobs with payload T, so whoever enqueues a job and
+// the handler that runs it agree on the payload.
+type Kind[T any] struct {
+	name string
+}
+
+func NewKind[T any](name string) Kind[T] {
+	return Kind[T]{name: name}
+}
+
+func (k Kind[T]) Name() string {
+	return k.name
+}
+
+func (k Kind[T]) Enqueue(q *Queue, payload T) (uint64, error) {
+	return q.enqueue(k.name, payload, 0)
+}
+
+// EnqueueIn runs the job no earlier than after the delay.
+func (k Kind[T]) EnqueueIn(q *Queue, payload T, delay time.Duration) (uint64, error) {
+	return q.enqueue(k.name, payload, delay)
+}
+
+// EnqueueTx stores the job within the transaction, it is only run if the transaction commits.
+func (k Kind[T]) EnqueueTx(q *Queue, tx pgx.Tx, payload T) (uint64, error) {
+	return q.enqueueTx(tx, k.name, payload)
+}
+
+// Handle sets the handler of the jobs of the kind.
+// A payload that can't be decoded is never retried.
+func (k Kind[T]) Handle(q *Queue, handler func(ctx context.Context, payload T) error) {
+	q.handle(k.name, func(ctx context.Context, data []byte) error {
+		var payload T
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return Permanent(err)
+		}
+		return handler(ctx, payload)
+	})
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import "time"
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDead    = "dead"
+)
+
+type Job struct {
+	Id          uint64    `json:"id" sql:"id"`
+	Kind        string    `json:"kind" sql:"kind"`
+	Payload     []byte    `json:"payload" sql:"payload"`
+	Status      string    `json:"status" sql:"status"`
+	Attempts    int       `json:"attempts" sql:"attempts"`
+	MaxAttempts int       `json:"maxAttempts" sql:"max_attempts"`
+	RunAt       time.Time `json:"runAt" sql:"run_at"`
+	LastError   *string   `json:"lastError" sql:"last_error"`
+}
+
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+	// Timeout is how long a job may stay running before another worker takes it again.
+	Timeout     time.Duration
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+func (cfg Config) backoff(attempts int) time.Duration {
+	return Backoff(cfg.MinBackoff, cfg.MaxBackoff, attempts)
+}
+
+// Backoff doubles the delay from min with every failed attempt, up to max.
+func Backoff(min, max time.Duration, attempts int) time.Duration {
+	delay := min
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	cfg := Config{MinBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{10, time.Minute},
+		{1000, time.Minute},
+	}
+
+	for _, test := range tests {
+		if got := cfg.backoff(test.attempts); got != test.want {
+			t.Errorf("backoff(%v) = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestBackoffMinAboveMax(t *testing.T) {
+	cfg := Config{MinBackoff: time.Hour, MaxBackoff: time.Minute}
+
+	if got := cfg.backoff(1); got != time.Minute {
+		t.Errorf("backoff(1) = %v, want %v", got, time.Minute)
+	}
+}
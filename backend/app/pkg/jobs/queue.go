@@ -0,0 +1,173 @@
+package jobs
+
+import (
+	"backend/pkg/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Handler runs a job with its raw payload. A returned error is retried
+// with backoff unless it is Permanent.
+type Handler func(ctx context.Context, payload []byte) error
+
+// Queue stores jobs in PostgreSQL and runs them in worker goroutines.
+// Several app replicas may run their workers against the same table.
+type Queue struct {
+	logger   *logging.Logger
+	storage  *Storage
+	cfg      Config
+	handlers map[string]Handler
+	mu       sync.RWMutex
+	ctx      context.Context
+}
+
+func NewQueue(ctx context.Context, storage *Storage, logger *logging.Logger, cfg Config) *Queue {
+	return &Queue{
+		logger:   logger,
+		storage:  storage,
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+		ctx:      ctx,
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error that retrying can't fix, the job (or an outbox
+// message) goes straight to the dead letters.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent tells whether the error, or one it wraps, was marked Permanent.
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// handle sets the handler of the kind, use Kind.Handle for a typed one.
+func (q *Queue) handle(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = handler
+}
+
+// enqueue stores a job to run after the delay, use Kind.Enqueue for a typed one.
+func (q *Queue) enqueue(kind string, payload interface{}, delay time.Duration) (uint64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	return q.storage.Insert(kind, data, time.Now().Add(delay), q.cfg.MaxAttempts)
+}
+
+// enqueueTx stores a job within the transaction, use Kind.EnqueueTx for a typed one.
+func (q *Queue) enqueueTx(tx pgx.Tx, kind string, payload interface{}) (uint64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	return q.storage.InsertTx(tx, kind, data, time.Now(), q.cfg.MaxAttempts)
+}
+
+// Run starts the workers and blocks until the context is done.
+func (q *Queue) Run() {
+	var wg sync.WaitGroup
+	for i := 0; i < q.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work()
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work() {
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// errors are already logged
+		q.storage.BuryStuck(q.cfg.Timeout)
+
+		for q.ctx.Err() == nil {
+			job, err := q.storage.Claim(q.cfg.Timeout)
+			if err != nil {
+				// pgx.ErrNoRows - nothing to do, other errors are already logged
+				break
+			}
+			q.process(job)
+		}
+
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) process(job *Job) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	var err error
+	if ok {
+		err = q.run(handler, job)
+	} else {
+		// Possibly enqueued by a newer replica, one that knows the kind may take it later.
+		err = fmt.Errorf("no handler for job kind '%v'", job.Kind)
+	}
+
+	if err == nil {
+		q.storage.Complete(job)
+		return
+	}
+
+	logger := q.logger.ExtraFields(map[string]interface{}{
+		"job":      job.Id,
+		"kind":     job.Kind,
+		"attempts": job.Attempts,
+	})
+
+	if IsPermanent(err) || job.Attempts >= job.MaxAttempts {
+		logger.Errorf("job is dead: %v", err)
+		q.storage.Bury(job, err)
+		return
+	}
+
+	logger.Warnf("job failed: %v", err)
+	q.storage.Retry(job, time.Now().Add(q.cfg.backoff(job.Attempts)), err)
+}
+
+// run calls the handler, a panic fails the job instead of the worker.
+func (q *Queue) run(handler Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(q.ctx, q.cfg.Timeout)
+	defer cancel()
+
+	return handler(ctx, job.Payload)
+}
@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewJobStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme = "public"
+	table  = "jobs"
+)
+
+// purgedPayload replaces the payload of dead jobs.
+var purgedPayload = sq.Expr("'null'::jsonb")
+
+var jobColumns = []string{"id", "kind", "payload", "status", "attempts", "max_attempts", "run_at", "last_error"}
+
+func scanJob(row pgx.Row, job *Job) error {
+	return row.Scan(
+		&job.Id, &job.Kind, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.LastError,
+	)
+}
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// querier runs a statement on the pool or within a transaction.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+func (s *Storage) Insert(kind string, payload []byte, runAt time.Time, maxAttempts int) (uint64, error) {
+	return s.insert(s.client, kind, payload, runAt, maxAttempts)
+}
+
+// InsertTx stores the job within the transaction, workers see it once it's committed.
+func (s *Storage) InsertTx(tx pgx.Tx, kind string, payload []byte, runAt time.Time, maxAttempts int) (uint64, error) {
+	return s.insert(tx, kind, payload, runAt, maxAttempts)
+}
+
+func (s *Storage) insert(q querier, kind string, payload []byte, runAt time.Time, maxAttempts int) (uint64, error) {
+	lastInsertId := uint64(0)
+
+	query := s.queryBuilder.Insert(scheme+"."+table).
+		Columns("kind", "payload", "run_at", "max_attempts").
+		Values(kind, payload, runAt, maxAttempts).
+		Suffix("RETURNING id")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	logger.Trace("Enqueueing job")
+	if err = q.QueryRow(s.ctx, sql, args...).Scan(&lastInsertId); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return lastInsertId, err
+	}
+
+	return lastInsertId, nil
+}
+
+// Claim takes the job that waits the longest and counts the attempt.
+// Locked rows are skipped, so several workers never run the same job.
+// A job running longer than timeout (its worker died) is taken again while
+// it has attempts left, see BuryStuck. Returns pgx.ErrNoRows when there is nothing to run.
+func (s *Storage) Claim(timeout time.Duration) (*Job, error) {
+	var job Job
+
+	next := sq.Select("id").
+		From(scheme + "." + table).
+		Where(sq.Or{
+			sq.And{sq.Eq{"status": StatusPending}, sq.Expr("run_at <= NOW()")},
+			sq.And{
+				sq.Eq{"status": StatusRunning},
+				sq.Lt{"locked_at": time.Now().Add(-timeout)},
+				sq.Expr("attempts < max_attempts"),
+			},
+		}).
+		OrderBy("run_at").
+		Limit(1).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("status", StatusRunning).
+		Set("locked_at", sq.Expr("NOW()")).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Where(sq.Expr("id = (?)", next)).
+		Suffix("RETURNING " + strings.Join(jobColumns, ", "))
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err = scanJob(s.client.QueryRow(s.ctx, sql, args...), &job); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			err = db.ErrScan(err)
+			logger.Error(err)
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// owned matches the job only while it is still the claim the worker got:
+// a job taken again after the timeout has more attempts, and the stale
+// worker must not finish it for the new one.
+func owned(job *Job) sq.Eq {
+	return sq.Eq{"id": job.Id, "attempts": job.Attempts, "status": StatusRunning}
+}
+
+// Complete removes a finished job together with its payload.
+func (s *Storage) Complete(job *Job) error {
+	query := s.queryBuilder.Delete(scheme + "." + table).
+		Where(owned(job))
+
+	return s.exec(query, "Completing job")
+}
+
+// Retry puts the job back to run again at runAt.
+func (s *Storage) Retry(job *Job, runAt time.Time, jobErr error) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("status", StatusPending).
+		Set("run_at", runAt).
+		Set("locked_at", nil).
+		Set("last_error", jobErr.Error()).
+		Where(owned(job))
+
+	return s.exec(query, "Retrying job")
+}
+
+// Bury moves the job to the dead letters, it is kept for inspection and never runs again.
+// The payload is purged, it may hold mail bodies with one-time tokens.
+func (s *Storage) Bury(job *Job, jobErr error) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("status", StatusDead).
+		Set("locked_at", nil).
+		Set("payload", purgedPayload).
+		Set("last_error", jobErr.Error()).
+		Where(owned(job))
+
+	return s.exec(query, "Burying job")
+}
+
+// BuryStuck moves the jobs that ran out of attempts without finishing to the
+// dead letters: a job that crashes its worker would otherwise be taken forever.
+func (s *Storage) BuryStuck(timeout time.Duration) error {
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("status", StatusDead).
+		Set("locked_at", nil).
+		Set("payload", purgedPayload).
+		Set("last_error", "the job did not finish within the timeout").
+		Where(sq.Eq{"status": StatusRunning}).
+		Where(sq.Lt{"locked_at": time.Now().Add(-timeout)}).
+		Where("attempts >= max_attempts")
+
+	return s.exec(query, "Burying stuck jobs")
+}
+
+func (s *Storage) exec(query sq.Sqlizer, message string) error {
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace(message)
+	if _, err = s.client.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import (
+	"backend/pkg/jobs"
+	"context"
+)
+
+// SendJob delivers a mail from the job queue, outside of the request.
+// The payload is the whole mail, links with tokens included, so the queue
+// deletes it with a completed job and purges it from a dead one.
+var SendJob = jobs.NewKind[Mail]("mail.send")
+
+// HandleJobs lets the queue send mails with the mailer.
+func (m *Mailer) HandleJobs(q *jobs.Queue) {
+	SendJob.Handle(q, func(_ context.Context, mail Mail) error {
+		return m.Send(mail)
+	})
+}
@@ -1,9 +1,10 @@
 package oauth
 
 import (
+	"backend/internal/auth"
 	"backend/internal/config"
+	"backend/internal/domain/twofactor"
 	"backend/internal/domain/user"
-	"backend/pkg/auth"
 	"backend/pkg/logging"
 	"backend/pkg/utils"
 	"net/http"
@@ -12,16 +13,21 @@ import (
 )
 
 type OAuthProvider struct {
-	logger  *logging.Logger
-	config  *config.Config
-	storage *user.Storage
+	logger    *logging.Logger
+	config    *config.Config
+	storage   *user.Storage
+	twoFactor *twofactor.Storage
+	// auth starts the sessions, an OAuth login is one like a password login.
+	auth *auth.Handler
 }
 
-func GetOAuthProvider(logger *logging.Logger, cfg *config.Config, storage *user.Storage) *OAuthProvider {
+func GetOAuthProvider(logger *logging.Logger, cfg *config.Config, storage *user.Storage, twoFactor *twofactor.Storage, authHandler *auth.Handler) *OAuthProvider {
 	return &OAuthProvider{
-		logger:  logger,
-		config:  cfg,
-		storage: storage,
+		logger:    logger,
+		config:    cfg,
+		storage:   storage,
+		twoFactor: twoFactor,
+		auth:      authHandler,
 	}
 }
 
@@ -40,17 +46,21 @@ func (oap *OAuthProvider) OAuth(authUser goth.User, w http.ResponseWriter, r *ht
 		return
 	}
 
-	jwtClaims := auth.AuthJwt{
-		Data: auth.AuthJwtData{
-			Id:    userId,
-			Email: authUser.Email,
-		},
-	}
-
-	token, err := auth.Encode(&jwtClaims, 10)
+	// The provider stands for the password, the second step is still ours.
+	status, err := oap.twoFactor.Status(userId)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	utils.WriteResponse(w, http.StatusOK, token)
+	if status.Enabled {
+		challenge, err := twofactor.NewChallenge(userId)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		utils.WriteResponse(w, http.StatusOK, challenge)
+		return
+	}
+
+	oap.auth.StartSession(w, r, userId, authUser.Email)
 }
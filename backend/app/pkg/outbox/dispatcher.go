@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"backend/pkg/jobs"
+	"backend/pkg/logging"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// batchSize is how many messages are delivered in one transaction.
+const batchSize = 100
+
+// Handler delivers a message. It gets the dispatcher's transaction, so
+// whatever it writes (e.g. a job) is committed together with the removal
+// of the message and a message is handed over exactly once.
+type Handler func(ctx context.Context, tx pgx.Tx, payload []byte) error
+
+// Dispatcher delivers outbox messages to their topic handlers.
+// Several replicas may run it at once.
+type Dispatcher struct {
+	logger   *logging.Logger
+	storage  *Storage
+	cfg      Config
+	handlers map[string]Handler
+	mu       sync.RWMutex
+	ctx      context.Context
+}
+
+func NewDispatcher(ctx context.Context, storage *Storage, logger *logging.Logger, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		logger:   logger,
+		storage:  storage,
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+		ctx:      ctx,
+	}
+}
+
+// handle sets the handler of the topic, use Topic.Handle for a typed one.
+func (d *Dispatcher) handle(topic string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[topic] = handler
+}
+
+// Run blocks until the context is done.
+func (d *Dispatcher) Run() {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		for d.ctx.Err() == nil {
+			delivered, err := d.dispatch()
+			// A short or failed batch waits for the next round.
+			if err != nil || delivered < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch delivers one batch and returns the number of delivered messages.
+// Every message gets a savepoint, a failed one is rolled back alone and
+// stays in the outbox with its error until its next attempt, which backs off
+// like a job. Out of attempts, or with a jobs.Permanent error, it is dead
+// and no longer taken.
+func (d *Dispatcher) dispatch() (int, error) {
+	delivered := 0
+
+	err := d.storage.client.BeginFunc(d.ctx, func(tx pgx.Tx) error {
+		messages, err := d.storage.claim(tx, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			d.mu.RLock()
+			handler, ok := d.handlers[message.Topic]
+			d.mu.RUnlock()
+
+			if !ok {
+				err = fmt.Errorf("no handler for outbox topic '%v'", message.Topic)
+			} else {
+				err = tx.BeginFunc(d.ctx, func(sp pgx.Tx) error {
+					if err := handler(d.ctx, sp, message.Payload); err != nil {
+						return err
+					}
+					return d.storage.remove(sp, message.Id)
+				})
+			}
+
+			if err != nil {
+				attempts := message.Attempts + 1
+				logger := d.logger.ExtraFields(map[string]interface{}{
+					"message":  message.Id,
+					"topic":    message.Topic,
+					"attempts": attempts,
+				})
+
+				dead := jobs.IsPermanent(err) || attempts >= d.cfg.MaxAttempts
+				if dead {
+					logger.Errorf("outbox message is dead: %v", err)
+				} else {
+					logger.Warnf("outbox message not delivered: %v", err)
+				}
+
+				nextAttempt := time.Now().Add(jobs.Backoff(d.cfg.MinBackoff, d.cfg.MaxBackoff, attempts))
+				if err = d.storage.fail(tx, message.Id, err, dead, nextAttempt); err != nil {
+					return err
+				}
+				continue
+			}
+
+			delivered++
+		}
+
+		return nil
+	})
+	if err != nil {
+		d.logger.Error(err)
+		return delivered, err
+	}
+
+	return delivered, nil
+}
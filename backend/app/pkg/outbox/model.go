@@ -0,0 +1,24 @@
+package outbox
+
+import "time"
+
+const (
+	StatusPending = "pending"
+	StatusDead    = "dead"
+)
+
+type Config struct {
+	Interval time.Duration
+	// MaxAttempts is how many times a message is tried before it is dead.
+	MaxAttempts int
+	// MinBackoff and MaxBackoff bound the delay before the next attempt, see jobs.Backoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+type Message struct {
+	Id       uint64 `json:"id" sql:"id"`
+	Topic    string `json:"topic" sql:"topic"`
+	Payload  []byte `json:"payload" sql:"payload"`
+	Attempts int    `json:"attempts" sql:"attempts"`
+}
@@ -0,0 +1,148 @@
+package outbox
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewOutboxStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme = "public"
+	table  = "outbox"
+)
+
+var queryBuilder = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// insert writes a message within the transaction of the change it announces.
+func insert(ctx context.Context, tx pgx.Tx, topic string, payload []byte) error {
+	query := queryBuilder.Insert(scheme+"."+table).
+		Columns("topic", "payload").
+		Values(topic, payload)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return db.ErrCreateQuery(err)
+	}
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return db.ErrDoQuery(err)
+	}
+
+	return nil
+}
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// claim locks the oldest pending messages due for an attempt till the end of the transaction.
+// Locked rows are skipped, so several dispatchers never take the same message.
+func (s *Storage) claim(tx pgx.Tx, limit uint64) ([]Message, error) {
+	query := s.queryBuilder.Select("id", "topic", "payload", "attempts").
+		From(scheme + "." + table).
+		Where(sq.Eq{"status": StatusPending}).
+		Where("next_attempt_at <= NOW()").
+		OrderBy("id").
+		Limit(limit).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	rows, err := tx.Query(s.ctx, sql, args...)
+	if err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]Message, 0)
+
+	for rows.Next() {
+		m := Message{}
+		if err = rows.Scan(&m.Id, &m.Topic, &m.Payload, &m.Attempts); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return nil, err
+		}
+
+		list = append(list, m)
+	}
+
+	return list, nil
+}
+
+func (s *Storage) remove(tx pgx.Tx, id uint64) error {
+	query := s.queryBuilder.Delete(scheme + "." + table).
+		Where(sq.Eq{"id": id})
+
+	return s.exec(tx, query, "Removing outbox message")
+}
+
+// fail keeps the message for an attempt at nextAttempt, or in the dead letters when it is dead.
+func (s *Storage) fail(tx pgx.Tx, id uint64, deliveryErr error, dead bool, nextAttempt time.Time) error {
+	status := StatusPending
+	if dead {
+		status = StatusDead
+	}
+
+	query := s.queryBuilder.Update(scheme+"."+table).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("status", status).
+		Set("next_attempt_at", nextAttempt).
+		Set("last_error", deliveryErr.Error()).
+		Where(sq.Eq{"id": id})
+
+	return s.exec(tx, query, "Failing outbox message")
+}
+
+func (s *Storage) exec(tx pgx.Tx, query sq.Sqlizer, message string) error {
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace(message)
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package outbox
+
+import (
+	"backend/pkg/jobs"
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Topic names messages with payload T, so the writer and the handler
+// that delivers them agree on the payload.
+type Topic[T any] struct {
+	name string
+}
+
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Add writes the message within the transaction, it is only delivered if the transaction commits.
+func (t Topic[T]) Add(ctx context.Context, tx pgx.Tx, payload T) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, tx, t.name, data)
+}
+
+// Handle sets the handler that delivers the messages of the topic.
+func (t Topic[T]) Handle(d *Dispatcher, handler func(ctx context.Context, tx pgx.Tx, payload T) error) {
+	d.handle(t.name, func(ctx context.Context, tx pgx.Tx, data []byte) error {
+		var payload T
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+		return handler(ctx, tx, payload)
+	})
+}
@@ -0,0 +1,158 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Table renders rows into a paginated printable PDF table. The header is
+// repeated on every page and a row is never split between two pages.
+// Go fonts are embedded, so cyrillic text is rendered as well as latin.
+type Table struct {
+	doc     *fpdf.Fpdf
+	title   string
+	columns []Column
+}
+
+type Column struct {
+	Title string
+	// Width in millimeters
+	Width float64
+}
+
+const (
+	fontFamily  = "go"
+	fontSize    = 9
+	titleSize   = 14
+	lineHeight  = 4.5
+	cellPadding = 1.5
+	margin      = 10
+)
+
+func NewTable(title string, columns []Column) *Table {
+	doc := fpdf.New("L", "mm", "A4", "")
+	doc.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	doc.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	doc.SetMargins(margin, margin, margin)
+	doc.SetCellMargin(0)
+	doc.SetAutoPageBreak(false, margin)
+	doc.AliasNbPages("")
+
+	t := &Table{
+		doc:     doc,
+		title:   title,
+		columns: columns,
+	}
+
+	doc.SetHeaderFunc(t.header)
+	doc.SetFooterFunc(t.footer)
+	doc.AddPage()
+
+	return t
+}
+
+func (t *Table) header() {
+	if t.doc.PageNo() == 1 && t.title != "" {
+		t.doc.SetFont(fontFamily, "B", titleSize)
+		t.doc.CellFormat(0, lineHeight*2, t.title, "", 1, "L", false, 0, "")
+		t.doc.Ln(lineHeight)
+	}
+
+	t.doc.SetFont(fontFamily, "B", fontSize)
+	t.doc.SetFillColor(230, 230, 230)
+	titles := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		titles[i] = column.Title
+	}
+	t.row(titles, true)
+	t.doc.SetFont(fontFamily, "", fontSize)
+}
+
+func (t *Table) footer() {
+	t.doc.SetY(-margin)
+	t.doc.SetFont(fontFamily, "", fontSize-1)
+	t.doc.CellFormat(0, lineHeight, fmt.Sprintf("%d / {nb}", t.doc.PageNo()), "", 0, "R", false, 0, "")
+}
+
+// AddRow adds a row, cells may contain line breaks. Cells taller than
+// a whole page are cut off.
+func (t *Table) AddRow(cells []string) {
+	_, pageHeight := t.doc.GetPageSize()
+	bottom := pageHeight - margin - lineHeight
+
+	if t.doc.GetY()+t.rowHeight(cells) > bottom {
+		t.doc.AddPage()
+	}
+	t.row(t.fit(cells, bottom-t.doc.GetY()), false)
+}
+
+func (t *Table) Output(w io.Writer) error {
+	return t.doc.Output(w)
+}
+
+func (t *Table) lines(text string, width float64) []string {
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(text, "\n") {
+		lines = append(lines, t.doc.SplitText(paragraph, width-2*cellPadding)...)
+	}
+	return lines
+}
+
+func (t *Table) rowHeight(cells []string) float64 {
+	height := 0.0
+	for i, cell := range cells {
+		if i >= len(t.columns) {
+			break
+		}
+		if h := float64(len(t.lines(cell, t.columns[i].Width))) * lineHeight; h > height {
+			height = h
+		}
+	}
+	return height + 2*cellPadding
+}
+
+// fit cuts cells down to the given height.
+func (t *Table) fit(cells []string, height float64) []string {
+	maxLines := int((height - 2*cellPadding) / lineHeight)
+	if maxLines < 1 {
+		maxLines = 1
+	}
+	result := make([]string, len(cells))
+	for i, cell := range cells {
+		if i >= len(t.columns) {
+			break
+		}
+		lines := t.lines(cell, t.columns[i].Width)
+		if len(lines) > maxLines {
+			lines = append(lines[:maxLines-1], "…")
+		}
+		result[i] = strings.Join(lines, "\n")
+	}
+	return result
+}
+
+func (t *Table) row(cells []string, fill bool) {
+	x, y := t.doc.GetXY()
+	height := t.rowHeight(cells)
+
+	style := "D"
+	if fill {
+		style = "FD"
+	}
+
+	for i, column := range t.columns {
+		t.doc.Rect(x, y, column.Width, height, style)
+		t.doc.SetXY(x+cellPadding, y+cellPadding)
+		if i < len(cells) {
+			t.doc.MultiCell(column.Width-2*cellPadding, lineHeight, cells[i], "", "L", false)
+		}
+		x += column.Width
+	}
+
+	t.doc.SetXY(margin, y+height)
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"backend/pkg/utils"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// KeyFunc returns the key of the request to limit, an empty one is not limited.
+type KeyFunc func(r *http.Request) string
+
+// maxPeek is as much of the body as the handlers read.
+const maxPeek = 1048576
+
+// ipPrefix marks the keys of ByIP. The address may be shared by many
+// clients, so the failures of one are never forgotten by the success of another.
+const ipPrefix = "ip:"
+
+// ByIP limits the client address. Behind a proxy that is not trusted, see
+// utils.UseTrustedProxies, every client has the proxy's address and shares its key.
+func ByIP(r *http.Request) string {
+	return ipPrefix + utils.ClientIP(r)
+}
+
+// ByEmail limits the email of a JSON body, or of a plain text body that is
+// nothing but the email (password reset).
+func ByEmail(r *http.Request) string {
+	var payload struct {
+		Email string `json:"email"`
+	}
+
+	body := PeekBody(r)
+	email := string(body)
+	if err := json.Unmarshal(body, &payload); err == nil {
+		email = payload.Email
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return "email:" + email
+}
+
+// PeekBody reads the body for a KeyFunc and leaves it in place for the handler.
+func PeekBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPeek))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
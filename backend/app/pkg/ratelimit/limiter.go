@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"backend/pkg/logging"
+	"backend/pkg/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Store keeps the states of the keys.
+type Store interface {
+	// Update changes the state of the key atomically, a new key starts with
+	// a zero State. change returns when the changed state expires.
+	Update(key string, change func(state *State) time.Time) error
+	// Prune drops the states that expired before now.
+	Prune(now time.Time) error
+}
+
+// Limiter is the middleware that applies rules to requests.
+type Limiter struct {
+	logger *logging.Logger
+	store  Store
+	// Now is the clock, tests pass a fixed one.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+// pruneInterval is how often the expired states are dropped.
+const pruneInterval = time.Minute
+
+func NewLimiter(store Store, logger *logging.Logger) *Limiter {
+	return &Limiter{
+		logger: logger,
+		store:  store,
+		Now:    time.Now,
+	}
+}
+
+// Limit applies the rule to every key of the request, named after the route.
+// The request is refused with Retry-After while any of the keys is limited.
+// Responses with 401 or 403 count as failures of all the keys, successful
+// ones reset the failures of the account keys. The failures of an address
+// only expire, a success does not speak for everyone behind it.
+func (l *Limiter) Limit(name string, rule Rule, next httprouter.Handle, keys ...KeyFunc) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		names := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if value := key(r); value != "" {
+				names = append(names, name+":"+value)
+			}
+		}
+
+		l.prune()
+
+		var wait time.Duration
+		for _, key := range names {
+			var keyWait time.Duration
+			now := l.Now()
+			err := l.update(key, rule, func(state *State) {
+				keyWait = state.Take(now, rule)
+			})
+			// An unavailable store does not lock everybody out.
+			if err != nil {
+				continue
+			}
+			if keyWait > wait {
+				wait = keyWait
+			}
+		}
+
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			utils.WriteErrorResponse(w, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r, ps)
+
+		failed := recorder.status == http.StatusUnauthorized || recorder.status == http.StatusForbidden
+		if !failed && recorder.status >= http.StatusBadRequest {
+			return
+		}
+		for _, key := range names {
+			if !failed && strings.HasPrefix(key, name+":"+ipPrefix) {
+				continue
+			}
+			now := l.Now()
+			l.update(key, rule, func(state *State) {
+				if failed {
+					state.Fail(now, rule)
+				} else {
+					state.Reset()
+				}
+			})
+		}
+	}
+}
+
+func (l *Limiter) update(key string, rule Rule, change func(state *State)) error {
+	err := l.store.Update(key, func(state *State) time.Time {
+		change(state)
+		return state.Expires(rule)
+	})
+	if err != nil {
+		l.logger.Errorf("rate limit of %v: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+func (l *Limiter) prune() {
+	now := l.Now()
+
+	l.mu.Lock()
+	if now.Sub(l.prunedAt) < pruneInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.prunedAt = now
+	l.mu.Unlock()
+
+	if err := l.store.Prune(now); err != nil {
+		l.logger.Errorf("rate limit prune: %v", err)
+	}
+}
+
+// statusRecorder remembers the status the handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"backend/pkg/logging"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+)
+
+func testLimiter(now *time.Time) *Limiter {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	limiter := NewLimiter(NewMemoryStore(), &logging.Logger{Entry: logrus.NewEntry(logger)})
+	limiter.Now = func() time.Time { return *now }
+	return limiter
+}
+
+// signin responds with the status in the password field.
+func signin(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, _ := io.ReadAll(r.Body)
+	if strings.Contains(string(body), `"password":"wrong"`) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func attempt(handle httprouter.Handle, ip, email, password string) *httptest.ResponseRecorder {
+	body := `{"email":"` + email + `","password":"` + password + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/signin", strings.NewReader(body))
+	r.RemoteAddr = ip + ":1234"
+
+	w := httptest.NewRecorder()
+	handle(w, r, nil)
+	return w
+}
+
+func TestLimitBurst(t *testing.T) {
+	now := testStart
+	handle := testLimiter(&now).Limit("signin", Rule{Every: time.Minute, Burst: 2}, signin, ByIP)
+
+	for i := 0; i < 2; i++ {
+		if w := attempt(handle, "10.0.0.1", "a@b.c", "right"); w.Code != http.StatusOK {
+			t.Fatalf("attempt %d: status = %v, want %v", i, w.Code, http.StatusOK)
+		}
+	}
+
+	w := attempt(handle, "10.0.0.1", "a@b.c", "right")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over burst: status = %v, want %v", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want %q", got, "60")
+	}
+
+	if w := attempt(handle, "10.0.0.2", "a@b.c", "right"); w.Code != http.StatusOK {
+		t.Errorf("another address: status = %v, want %v", w.Code, http.StatusOK)
+	}
+
+	now = now.Add(time.Minute)
+	if w := attempt(handle, "10.0.0.1", "a@b.c", "right"); w.Code != http.StatusOK {
+		t.Errorf("refilled: status = %v, want %v", w.Code, http.StatusOK)
+	}
+}
+
+func TestLimitLockout(t *testing.T) {
+	rule := Rule{
+		Every: time.Second, Burst: 100,
+		Lockout: Lockout{Threshold: 3, Window: time.Hour, Base: time.Minute, Max: time.Hour},
+	}
+
+	now := testStart
+	handle := testLimiter(&now).Limit("signin", rule, signin, ByIP, ByEmail)
+
+	for i := 0; i < 3; i++ {
+		if w := attempt(handle, "10.0.0.1", "a@b.c", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status = %v, want %v", i, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	if w := attempt(handle, "10.0.0.2", "a@b.c", "right"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("locked email: status = %v, want %v", w.Code, http.StatusTooManyRequests)
+	}
+	if w := attempt(handle, "10.0.0.1", "d@e.f", "right"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("locked address: status = %v, want %v", w.Code, http.StatusTooManyRequests)
+	}
+
+	now = now.Add(time.Minute)
+	if w := attempt(handle, "10.0.0.2", "a@b.c", "right"); w.Code != http.StatusOK {
+		t.Errorf("lockout over: status = %v, want %v", w.Code, http.StatusOK)
+	}
+}
+
+func TestLimitSuccessKeepsAddressFailures(t *testing.T) {
+	rule := Rule{
+		Every: time.Second, Burst: 100,
+		Lockout: Lockout{Threshold: 3, Window: time.Hour, Base: time.Minute, Max: time.Hour},
+	}
+
+	now := testStart
+	handle := testLimiter(&now).Limit("signin", rule, signin, ByIP, ByEmail)
+
+	// Two accounts guessed from one address, a success of another account
+	// behind the same address does not forgive them.
+	attempt(handle, "10.0.0.1", "a@b.c", "wrong")
+	attempt(handle, "10.0.0.1", "d@e.f", "wrong")
+	if w := attempt(handle, "10.0.0.1", "g@h.i", "right"); w.Code != http.StatusOK {
+		t.Fatalf("success: status = %v, want %v", w.Code, http.StatusOK)
+	}
+	attempt(handle, "10.0.0.1", "j@k.l", "wrong")
+
+	if w := attempt(handle, "10.0.0.1", "g@h.i", "right"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("address: status = %v, want %v", w.Code, http.StatusTooManyRequests)
+	}
+
+	// The success does forgive the account itself.
+	attempt(handle, "10.0.0.2", "m@n.o", "wrong")
+	attempt(handle, "10.0.0.3", "m@n.o", "wrong")
+	attempt(handle, "10.0.0.4", "m@n.o", "right")
+	attempt(handle, "10.0.0.5", "m@n.o", "wrong")
+	if w := attempt(handle, "10.0.0.6", "m@n.o", "right"); w.Code != http.StatusOK {
+		t.Errorf("account: status = %v, want %v", w.Code, http.StatusOK)
+	}
+}
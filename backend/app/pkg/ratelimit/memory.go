@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the states in process, it fits a single replica.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]*memoryState
+}
+
+type memoryState struct {
+	State
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string]*memoryState),
+	}
+}
+
+func (s *MemoryStore) Update(key string, change func(state *State) time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[key]
+	if !ok {
+		state = &memoryState{}
+		s.states[key] = state
+	}
+	state.expires = change(&state.State)
+
+	return nil
+}
+
+func (s *MemoryStore) Prune(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, state := range s.states {
+		if state.expires.Before(now) {
+			delete(s.states, key)
+		}
+	}
+
+	return nil
+}
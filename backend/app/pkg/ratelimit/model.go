@@ -0,0 +1,102 @@
+package ratelimit
+
+import "time"
+
+// Rule is a token bucket: Burst requests at once, then one more every Every.
+// A zero Every does not limit.
+type Rule struct {
+	Every   time.Duration
+	Burst   int
+	Lockout Lockout
+}
+
+// Lockout blocks a key after Threshold failures within Window. The first
+// lockout lasts Base, each further failure doubles it up to Max.
+// A zero Threshold never locks out.
+type Lockout struct {
+	Threshold int
+	Window    time.Duration
+	Base      time.Duration
+	Max       time.Duration
+}
+
+// State is what a Store keeps per key. Zero times are not set.
+type State struct {
+	Tokens      float64
+	UpdatedAt   time.Time
+	Failures    int
+	FailedAt    time.Time
+	LockedUntil time.Time
+}
+
+// Take spends a request of the bucket. When none is left, or the key is
+// locked out, nothing is spent and the time to wait is returned.
+func (s *State) Take(now time.Time, rule Rule) time.Duration {
+	if now.Before(s.LockedUntil) {
+		return s.LockedUntil.Sub(now)
+	}
+	if rule.Every <= 0 {
+		return 0
+	}
+
+	if s.UpdatedAt.IsZero() {
+		s.Tokens = float64(rule.Burst)
+	} else if now.After(s.UpdatedAt) {
+		s.Tokens += float64(now.Sub(s.UpdatedAt)) / float64(rule.Every)
+		if s.Tokens > float64(rule.Burst) {
+			s.Tokens = float64(rule.Burst)
+		}
+	}
+	s.UpdatedAt = now
+
+	if s.Tokens < 1 {
+		return time.Duration((1 - s.Tokens) * float64(rule.Every))
+	}
+	s.Tokens--
+	return 0
+}
+
+// Fail counts a failed attempt and locks the key out once there are too many.
+func (s *State) Fail(now time.Time, rule Rule) {
+	lockout := rule.Lockout
+	if lockout.Threshold <= 0 {
+		return
+	}
+
+	if now.Sub(s.FailedAt) > lockout.Window {
+		s.Failures = 0
+	}
+	s.Failures++
+	s.FailedAt = now
+
+	if s.Failures < lockout.Threshold {
+		return
+	}
+	duration := lockout.Base
+	for i := lockout.Threshold; i < s.Failures && duration < lockout.Max; i++ {
+		duration *= 2
+	}
+	if duration > lockout.Max {
+		duration = lockout.Max
+	}
+	s.LockedUntil = now.Add(duration)
+}
+
+// Reset forgets the failures after a successful attempt.
+func (s *State) Reset() {
+	s.Failures = 0
+	s.FailedAt = time.Time{}
+	s.LockedUntil = time.Time{}
+}
+
+// Expires is when the state is no different from a new one and can be dropped.
+func (s *State) Expires(rule Rule) time.Time {
+	expires := s.UpdatedAt.Add(rule.Every * time.Duration(rule.Burst))
+	if failures := s.FailedAt.Add(rule.Lockout.Window); failures.After(expires) {
+		expires = failures
+	}
+	if s.LockedUntil.After(expires) {
+		expires = s.LockedUntil
+	}
+	return expires
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestStateTake(t *testing.T) {
+	rule := Rule{Every: time.Minute, Burst: 2}
+
+	tests := []struct {
+		name  string
+		state State
+		now   time.Time
+		rule  Rule
+		want  time.Duration
+		after float64
+	}{
+		{"new key", State{}, testStart, rule, 0, 1},
+		{"last token", State{Tokens: 1, UpdatedAt: testStart}, testStart, rule, 0, 0},
+		{"empty", State{Tokens: 0, UpdatedAt: testStart}, testStart, rule, time.Minute, 0},
+		{"partly refilled", State{Tokens: 0.5, UpdatedAt: testStart}, testStart, rule, 30 * time.Second, 0.5},
+		{"refilled", State{Tokens: 0, UpdatedAt: testStart}, testStart.Add(time.Minute), rule, 0, 0},
+		{"refill capped by burst", State{Tokens: 0, UpdatedAt: testStart}, testStart.Add(time.Hour), rule, 0, 1},
+		{"locked out", State{Tokens: 2, UpdatedAt: testStart, LockedUntil: testStart.Add(time.Hour)}, testStart, rule, time.Hour, 2},
+		{"not limited", State{}, testStart, Rule{}, 0, 0},
+	}
+
+	for _, test := range tests {
+		state := test.state
+		if got := state.Take(test.now, test.rule); got != test.want {
+			t.Errorf("%v: Take = %v, want %v", test.name, got, test.want)
+		}
+		if state.Tokens != test.after {
+			t.Errorf("%v: tokens = %v, want %v", test.name, state.Tokens, test.after)
+		}
+	}
+}
+
+func TestStateFail(t *testing.T) {
+	rule := Rule{Lockout: Lockout{Threshold: 3, Window: time.Hour, Base: time.Minute, Max: 5 * time.Minute}}
+
+	tests := []struct {
+		name     string
+		state    State
+		rule     Rule
+		failures int
+		locked   time.Duration
+	}{
+		{"first failure", State{}, rule, 1, 0},
+		{"below threshold", State{Failures: 1, FailedAt: testStart}, rule, 2, 0},
+		{"at threshold", State{Failures: 2, FailedAt: testStart}, rule, 3, time.Minute},
+		{"doubled", State{Failures: 3, FailedAt: testStart}, rule, 4, 2 * time.Minute},
+		{"capped", State{Failures: 10, FailedAt: testStart}, rule, 11, 5 * time.Minute},
+		{"window over", State{Failures: 2, FailedAt: testStart.Add(-2 * time.Hour)}, rule, 1, 0},
+		{"no lockout", State{Failures: 5, FailedAt: testStart}, Rule{}, 5, 0},
+	}
+
+	for _, test := range tests {
+		state := test.state
+		state.Fail(testStart, test.rule)
+
+		if state.Failures != test.failures {
+			t.Errorf("%v: failures = %v, want %v", test.name, state.Failures, test.failures)
+		}
+		var locked time.Duration
+		if !state.LockedUntil.IsZero() {
+			locked = state.LockedUntil.Sub(testStart)
+		}
+		if locked != test.locked {
+			t.Errorf("%v: locked for %v, want %v", test.name, locked, test.locked)
+		}
+	}
+}
+
+func TestStateReset(t *testing.T) {
+	state := State{Tokens: 1, UpdatedAt: testStart, Failures: 4, FailedAt: testStart, LockedUntil: testStart.Add(time.Hour)}
+	state.Reset()
+
+	want := State{Tokens: 1, UpdatedAt: testStart}
+	if state != want {
+		t.Errorf("Reset = %+v, want %+v", state, want)
+	}
+}
+
+func TestStateExpires(t *testing.T) {
+	rule := Rule{Every: time.Minute, Burst: 5, Lockout: Lockout{Threshold: 3, Window: time.Hour}}
+
+	tests := []struct {
+		name  string
+		state State
+		want  time.Time
+	}{
+		{"bucket", State{UpdatedAt: testStart}, testStart.Add(5 * time.Minute)},
+		{"failures", State{UpdatedAt: testStart, FailedAt: testStart}, testStart.Add(time.Hour)},
+		{"lockout", State{UpdatedAt: testStart, FailedAt: testStart, LockedUntil: testStart.Add(2 * time.Hour)}, testStart.Add(2 * time.Hour)},
+	}
+
+	for _, test := range tests {
+		if got := test.state.Expires(rule); !got.Equal(test.want) {
+			t.Errorf("%v: Expires = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
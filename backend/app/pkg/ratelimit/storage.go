@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"backend/pkg/client/postgresql"
+	db "backend/pkg/client/postgresql/model"
+	"backend/pkg/logging"
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+// Storage keeps the states in Postgres, so that every replica shares the limits.
+type Storage struct {
+	queryBuilder sq.StatementBuilderType
+	client       postgresql.Client
+	logger       *logging.Logger
+	ctx          context.Context
+}
+
+func NewRateLimitStorage(ctx context.Context, client postgresql.Client, logger *logging.Logger) *Storage {
+	return &Storage{
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		client:       client,
+		logger:       logger,
+		ctx:          ctx,
+	}
+}
+
+const (
+	scheme = "public"
+	table  = "rate_limits"
+)
+
+func (s *Storage) queryLogger(sql, table string, args []interface{}) *logging.Logger {
+	return s.logger.ExtraFields(map[string]interface{}{
+		"sql":   sql,
+		"table": table,
+		"args":  args,
+	})
+}
+
+// Update locks the row of the key, it is created first so that concurrent
+// requests for a new key wait for each other too.
+func (s *Storage) Update(key string, change func(state *State) time.Time) error {
+	return s.client.BeginFunc(s.ctx, func(tx pgx.Tx) error {
+		var state State
+		var updatedAt, failedAt, lockedUntil *time.Time
+
+		create := s.queryBuilder.Insert(scheme+"."+table).
+			Columns("key", "expires_at").
+			Values(key, time.Now()).
+			Suffix("ON CONFLICT (key) DO NOTHING")
+
+		if err := s.exec(tx, create); err != nil {
+			return err
+		}
+
+		query := s.queryBuilder.Select("tokens", "updated_at", "failures", "failed_at", "locked_until").
+			From(scheme + "." + table).
+			Where(sq.Eq{"key": key}).
+			Suffix("FOR UPDATE")
+
+		sql, args, err := query.ToSql()
+		logger := s.queryLogger(sql, table, args)
+		if err != nil {
+			err = db.ErrCreateQuery(err)
+			logger.Error(err)
+			return err
+		}
+
+		if err = tx.QueryRow(s.ctx, sql, args...).Scan(
+			&state.Tokens, &updatedAt, &state.Failures, &failedAt, &lockedUntil,
+		); err != nil {
+			err = db.ErrScan(err)
+			logger.Error(err)
+			return err
+		}
+		state.UpdatedAt = timeOf(updatedAt)
+		state.FailedAt = timeOf(failedAt)
+		state.LockedUntil = timeOf(lockedUntil)
+
+		expires := change(&state)
+
+		update := s.queryBuilder.Update(scheme+"."+table).
+			Set("tokens", state.Tokens).
+			Set("updated_at", nullTime(state.UpdatedAt)).
+			Set("failures", state.Failures).
+			Set("failed_at", nullTime(state.FailedAt)).
+			Set("locked_until", nullTime(state.LockedUntil)).
+			Set("expires_at", expires).
+			Where(sq.Eq{"key": key})
+
+		return s.exec(tx, update)
+	})
+}
+
+func (s *Storage) Prune(now time.Time) error {
+	query := s.queryBuilder.Delete(scheme + "." + table).
+		Where(sq.Lt{"expires_at": now})
+
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	logger.Trace("Pruning rate limits")
+	if _, err = s.client.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) exec(tx pgx.Tx, query sq.Sqlizer) error {
+	sql, args, err := query.ToSql()
+	logger := s.queryLogger(sql, table, args)
+	if err != nil {
+		err = db.ErrCreateQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	if _, err = tx.Exec(s.ctx, sql, args...); err != nil {
+		err = db.ErrDoQuery(err)
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// nullTime stores a zero time as NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,143 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with
+// the HMAC-SHA1, 6 digits, 30 seconds defaults authenticator apps expect.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSecret = errors.New("totp: invalid secret")
+
+// secretBytes is the key length RFC 4226 recommends for HMAC-SHA1.
+const secretBytes = 20
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP generates and validates codes. Now is the clock, tests pass a fixed one.
+type TOTP struct {
+	Digits int
+	Period time.Duration
+	// Skew is how many periods before and after the current one are also
+	// accepted, to make up for clock drift and typing time.
+	Skew int
+	Now  func() time.Time
+}
+
+// New returns the defaults used by authenticator apps.
+func New() *TOTP {
+	return &TOTP{
+		Digits: 6,
+		Period: time.Second * 30,
+		Skew:   1,
+		Now:    time.Now,
+	}
+}
+
+// GenerateSecret returns a random base32 secret.
+func GenerateSecret() (string, error) {
+	key := make([]byte, secretBytes)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(key), nil
+}
+
+// Step returns the number of periods since the Unix epoch at the time.
+func (t *TOTP) Step(at time.Time) uint64 {
+	return uint64(at.Unix()) / uint64(t.Period/time.Second)
+}
+
+// Code returns the code of the secret at the time.
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, t.Step(at), t.Digits), nil
+}
+
+// Validate checks the code against the current time and returns the step it
+// belongs to. Callers keep the last accepted step and refuse the code when
+// Replayed, otherwise a code could be used twice.
+func (t *TOTP) Validate(secret string, code string) (uint64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != t.Digits {
+		return 0, false, nil
+	}
+
+	current := t.Step(t.Now())
+	for i := -t.Skew; i <= t.Skew; i++ {
+		if i < 0 && current < uint64(-i) {
+			continue
+		}
+		step := current + uint64(i)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step, t.Digits)), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// Replayed reports whether the step is not after the last accepted one,
+// last is nil before the first code is accepted.
+func Replayed(step uint64, last *uint64) bool {
+	return last != nil && step <= *last
+}
+
+// URI returns the otpauth:// link authenticator apps import, usually shown as a QR code.
+func (t *TOTP) URI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(t.Digits))
+	query.Set("period", fmt.Sprint(int(t.Period/time.Second)))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// hotp is the HMAC-based one-time password of RFC 4226.
+func hotp(key []byte, counter uint64, digits int) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := encoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
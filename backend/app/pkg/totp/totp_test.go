@@ -0,0 +1,148 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	generator := New()
+	generator.Digits = 8
+
+	for _, test := range tests {
+		code, err := generator.Code(rfcSecret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%v): %v", test.unix, err)
+		}
+		if code != test.code {
+			t.Errorf("Code(%v) = %v, want %v", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	generator := New()
+	generator.Now = func() time.Time { return now }
+	current := generator.Step(now)
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		valid bool
+	}{
+		{"current period", now, true},
+		{"one period behind", now.Add(-generator.Period), true},
+		{"one period ahead", now.Add(generator.Period), true},
+		{"two periods behind", now.Add(-2 * generator.Period), false},
+		{"two periods ahead", now.Add(2 * generator.Period), false},
+	}
+
+	for _, test := range tests {
+		code, err := generator.Code(rfcSecret, test.at)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		step, ok, err := generator.Validate(rfcSecret, code)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if ok != test.valid {
+			t.Errorf("%v: valid = %v, want %v", test.name, ok, test.valid)
+		}
+		if ok && step != generator.Step(test.at) {
+			t.Errorf("%v: step = %v, want %v (current %v)", test.name, step, generator.Step(test.at), current)
+		}
+	}
+}
+
+func TestValidateRejectsMalformed(t *testing.T) {
+	generator := New()
+
+	if _, ok, _ := generator.Validate(rfcSecret, "12345"); ok {
+		t.Error("a code of the wrong length was accepted")
+	}
+	if _, _, err := generator.Validate("not base32!", "123456"); err != ErrInvalidSecret {
+		t.Errorf("err = %v, want %v", err, ErrInvalidSecret)
+	}
+}
+
+// TestReplay walks through what the callers do with last_step: Validate
+// accepts the same code again, only Replayed refuses it.
+func TestReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	generator := New()
+	generator.Now = func() time.Time { return now }
+
+	code, err := generator.Code(rfcSecret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lastStep *uint64
+
+	step, ok, err := generator.Validate(rfcSecret, code)
+	if err != nil || !ok {
+		t.Fatalf("first use: ok = %v, err = %v", ok, err)
+	}
+	if Replayed(step, lastStep) {
+		t.Fatal("first use was refused as a replay")
+	}
+	used := step
+	lastStep = &used
+
+	again, ok, err := generator.Validate(rfcSecret, code)
+	if err != nil || !ok {
+		t.Fatalf("second use: ok = %v, err = %v", ok, err)
+	}
+	if again != step {
+		t.Fatalf("second use: step = %v, want %v", again, step)
+	}
+	if !Replayed(again, lastStep) {
+		t.Error("the same code was accepted twice")
+	}
+
+	// A code of the previous period is still within the skew, but older than the last one used.
+	previous, err := generator.Code(rfcSecret, now.Add(-generator.Period))
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, ok, err := generator.Validate(rfcSecret, previous)
+	if err != nil || !ok {
+		t.Fatalf("previous code: ok = %v, err = %v", ok, err)
+	}
+	if !Replayed(older, lastStep) {
+		t.Error("a code older than the last one used was accepted")
+	}
+
+	// The next period's code moves on.
+	now = now.Add(generator.Period)
+	next, err := generator.Code(rfcSecret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, ok, err = generator.Validate(rfcSecret, next)
+	if err != nil || !ok {
+		t.Fatalf("next code: ok = %v, err = %v", ok, err)
+	}
+	if Replayed(step, lastStep) {
+		t.Error("the code of the next period was refused")
+	}
+}
@@ -19,9 +19,13 @@ type ApiError struct {
 	Title  string `json:"title"`
 }
 
+// Meta describes a list page. Totals are only set when they were counted,
+// which page mode does by default. Next and Prev are set in cursor mode.
 type Meta struct {
-	TotalItems uint64 `json:"totalItems"`
-	TotalPages uint64 `json:"totalPages"`
+	TotalItems *uint64 `json:"totalItems,omitempty"`
+	TotalPages *uint64 `json:"totalPages,omitempty"`
+	Next       *string `json:"next,omitempty"`
+	Prev       *string `json:"prev,omitempty"`
 }
 
 type MetaData struct {
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// StaticSegments lets static routes share a path segment with a wildcard,
+// which httprouter refuses to register (e.g. /api/smers/search next to
+// /api/smers/:smerId). Requests whose param value matches one of the routes
+// go to that handler, all the others go to next.
+func StaticSegments(param string, routes map[string]httprouter.Handle, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if handle, ok := routes[ps.ByName(param)]; ok {
+			handle(w, r, ps)
+			return
+		}
+		if next == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		next(w, r, ps)
+	}
+}
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Full-text search over smers. The 'russian' configuration stems cyrillic words
+-- with the russian snowball stemmer and ascii words with the english one, so a
+-- single configuration covers both languages our users write in.
+ALTER TABLE smers
+ADD COLUMN search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION trigger_set_smers_search_vector()
+    RETURNS TRIGGER AS
+$$
+BEGIN
+    NEW.search_vector =
+        setweight(to_tsvector('russian', coalesce(NEW.situation, '')), 'A') ||
+        setweight(to_tsvector('russian', array_to_string(NEW.thoughts, ' ')), 'B') ||
+        setweight(to_tsvector('russian', array_to_string(NEW.emotions, ' ')), 'C') ||
+        setweight(to_tsvector('russian', array_to_string(NEW.reactions, ' ')), 'C');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER set_smers_search_vector
+    BEFORE INSERT OR UPDATE OF situation, thoughts, emotions, reactions
+    ON smers
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_smers_search_vector();
+
+-- Fill the vector for already existing rows without touching updated_at.
+ALTER TABLE smers DISABLE TRIGGER set_smers_timestamp;
+
+UPDATE smers
+SET search_vector =
+        setweight(to_tsvector('russian', coalesce(situation, '')), 'A') ||
+        setweight(to_tsvector('russian', array_to_string(thoughts, ' ')), 'B') ||
+        setweight(to_tsvector('russian', array_to_string(emotions, ' ')), 'C') ||
+        setweight(to_tsvector('russian', array_to_string(reactions, ' ')), 'C');
+
+ALTER TABLE smers ENABLE TRIGGER set_smers_timestamp;
+
+CREATE INDEX smers_search_vector_idx ON smers USING GIN (search_vector);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX smers_search_vector_idx;
+DROP TRIGGER set_smers_search_vector ON smers;
+DROP FUNCTION trigger_set_smers_search_vector();
+
+ALTER TABLE smers
+DROP COLUMN search_vector;
+-- +goose StatementEnd
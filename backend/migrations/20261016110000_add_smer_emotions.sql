@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Emotions with intensity ratings. smers.emotions keeps the plain names
+-- in the same order for clients that don't know about ratings.
+CREATE TABLE smer_emotions
+(
+    id               BIGSERIAL                                 NOT NULL PRIMARY KEY,
+    smer_id          BIGINT REFERENCES smers ON DELETE CASCADE NOT NULL,
+    position         SMALLINT                                  NOT NULL,
+    name             TEXT                                      NOT NULL,
+    intensity_before SMALLINT CHECK (intensity_before BETWEEN 0 AND 100),
+    intensity_after  SMALLINT CHECK (intensity_after BETWEEN 0 AND 100),
+
+    UNIQUE (smer_id, position)
+);
+
+INSERT INTO smer_emotions (smer_id, position, name)
+SELECT smers.id, emotion.position - 1, emotion.name
+FROM smers,
+     unnest(smers.emotions) WITH ORDINALITY AS emotion(name, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE smer_emotions;
+-- +goose StatementEnd
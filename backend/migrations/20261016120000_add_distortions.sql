@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Catalog of cognitive distortions a thought can be tagged with.
+CREATE TABLE distortions
+(
+    id          BIGSERIAL   NOT NULL PRIMARY KEY,
+    code        VARCHAR(40) NOT NULL UNIQUE,
+    name        TEXT        NOT NULL,
+    description TEXT        NOT NULL
+);
+
+INSERT INTO distortions (code, name, description)
+VALUES ('all-or-nothing', 'All-or-nothing thinking',
+        'Seeing things in black and white categories, with nothing in between.'),
+       ('overgeneralization', 'Overgeneralization',
+        'Taking a single negative event as a never-ending pattern of defeat.'),
+       ('mental-filter', 'Mental filter',
+        'Dwelling on a single negative detail and ignoring everything else.'),
+       ('disqualifying-positive', 'Disqualifying the positive',
+        'Insisting that positive experiences "don''t count" for some reason.'),
+       ('mind-reading', 'Mind reading',
+        'Concluding that someone is reacting negatively to you without checking it.'),
+       ('fortune-telling', 'Fortune telling',
+        'Predicting that things will turn out badly as if it were an established fact.'),
+       ('catastrophizing', 'Catastrophizing',
+        'Exaggerating the importance of problems and expecting the worst possible outcome.'),
+       ('emotional-reasoning', 'Emotional reasoning',
+        'Assuming that negative emotions reflect the way things really are.'),
+       ('should-statements', 'Should statements',
+        'Motivating yourself or judging others with "should", "must" and "ought to".'),
+       ('labeling', 'Labeling',
+        'Attaching a negative label to yourself or others instead of describing the behaviour.'),
+       ('personalization', 'Personalization',
+        'Seeing yourself as the cause of a negative event you were not primarily responsible for.'),
+       ('blaming', 'Blaming',
+        'Holding other people entirely responsible for your pain, or blaming yourself for everything.');
+
+-- Distortions of a single thought, addressed by its index in smers.thoughts.
+CREATE TABLE thought_distortions
+(
+    smer_id       BIGINT REFERENCES smers ON DELETE CASCADE NOT NULL,
+    thought_index SMALLINT                                  NOT NULL,
+    distortion_id BIGINT REFERENCES distortions             NOT NULL,
+
+    PRIMARY KEY (smer_id, thought_index, distortion_id)
+);
+
+CREATE INDEX thought_distortions_distortion_idx ON thought_distortions (distortion_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX thought_distortions_distortion_idx;
+DROP TABLE thought_distortions;
+DROP TABLE distortions;
+-- +goose StatementEnd
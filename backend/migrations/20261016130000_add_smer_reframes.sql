@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Rational response to an automatic thought, addressed by its index in smers.thoughts.
+CREATE TABLE smer_reframes
+(
+    id                  BIGSERIAL                                 NOT NULL PRIMARY KEY,
+    smer_id             BIGINT REFERENCES smers ON DELETE CASCADE NOT NULL,
+    thought_index       SMALLINT                                  NOT NULL,
+
+    evidence_for        TEXT[]                                    NOT NULL DEFAULT '{}',
+    evidence_against    TEXT[]                                    NOT NULL DEFAULT '{}',
+    alternative_thought TEXT                                      NOT NULL DEFAULT '',
+
+    created_at          timestamptz                               NOT NULL DEFAULT NOW(),
+    updated_at          timestamptz                               NOT NULL DEFAULT NOW(),
+
+    UNIQUE (smer_id, thought_index)
+);
+
+CREATE TRIGGER set_smer_reframes_timestamp
+    BEFORE UPDATE
+    ON smer_reframes
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE smer_reframes;
+-- +goose StatementEnd
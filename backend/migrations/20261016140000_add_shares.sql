@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Read access to a client's smers granted to a therapist.
+-- A share is active once accepted, until it is revoked or expires.
+CREATE TABLE shares
+(
+    id              BIGSERIAL               NOT NULL PRIMARY KEY,
+    client_id       BIGINT REFERENCES users NOT NULL,
+    therapist_email VARCHAR(100)            NOT NULL,
+    therapist_id    BIGINT REFERENCES users,
+    token           TEXT                    NOT NULL UNIQUE,
+
+    accepted_at     timestamptz,
+    revoked_at      timestamptz,
+    expires_at      timestamptz,
+
+    created_at      timestamptz             NOT NULL DEFAULT NOW(),
+    updated_at      timestamptz             NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX shares_client_therapist_idx ON shares (client_id, lower(therapist_email)) WHERE revoked_at IS NULL;
+CREATE INDEX shares_therapist_idx ON shares (therapist_id, client_id) WHERE revoked_at IS NULL;
+
+CREATE TRIGGER set_shares_timestamp
+    BEFORE UPDATE
+    ON shares
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE shares;
+-- +goose StatementEnd
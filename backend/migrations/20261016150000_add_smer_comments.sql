@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Comments left on a smer, or on a single thought of it, by the client
+-- and the therapists the smers are shared with.
+CREATE TABLE smer_comments
+(
+    id            BIGSERIAL                                 NOT NULL PRIMARY KEY,
+    smer_id       BIGINT REFERENCES smers ON DELETE CASCADE NOT NULL,
+    thought_index SMALLINT,
+    parent_id     BIGINT REFERENCES smer_comments,
+    author_id     BIGINT REFERENCES users                   NOT NULL,
+    body          TEXT                                      NOT NULL,
+
+    created_at    timestamptz                               NOT NULL DEFAULT NOW(),
+    updated_at    timestamptz                               NOT NULL DEFAULT NOW(),
+    deleted_at    timestamptz
+);
+
+CREATE INDEX smer_comments_smer_idx ON smer_comments (smer_id);
+
+-- Previous bodies of edited comments.
+CREATE TABLE smer_comment_edits
+(
+    id         BIGSERIAL                                         NOT NULL PRIMARY KEY,
+    comment_id BIGINT REFERENCES smer_comments ON DELETE CASCADE NOT NULL,
+    body       TEXT                                              NOT NULL,
+
+    created_at timestamptz                                       NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX smer_comment_edits_comment_idx ON smer_comment_edits (comment_id);
+
+CREATE TRIGGER set_smer_comments_timestamp
+    BEFORE UPDATE
+    ON smer_comments
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE smer_comment_edits;
+DROP TABLE smer_comments;
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- "Download my data" archives, built in the background and emailed as a link.
+CREATE TABLE account_exports
+(
+    id         BIGSERIAL                                  NOT NULL PRIMARY KEY,
+    user_id    BIGINT REFERENCES users ON DELETE CASCADE NOT NULL,
+    token      TEXT                                       NOT NULL UNIQUE,
+    status     VARCHAR(20)                                NOT NULL DEFAULT 'pending',
+    path       TEXT,
+    error      TEXT,
+
+    expires_at timestamptz,
+    created_at timestamptz                                NOT NULL DEFAULT NOW(),
+    updated_at timestamptz                                NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX account_exports_status_idx ON account_exports (status, id);
+
+CREATE TRIGGER set_account_exports_timestamp
+    BEFORE UPDATE
+    ON account_exports
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- Scheduled account erasures, a row is removed on cancel or together with the user.
+CREATE TABLE account_erasures
+(
+    user_id      BIGINT REFERENCES users ON DELETE CASCADE NOT NULL PRIMARY KEY,
+    scheduled_at timestamptz                                NOT NULL,
+    created_at   timestamptz                                NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX account_erasures_scheduled_idx ON account_erasures (scheduled_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE account_erasures;
+DROP TABLE account_exports;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Snapshots of a smer after each write, revision 1 is the created entry.
+CREATE TABLE smer_revisions
+(
+    id         BIGSERIAL                                  NOT NULL PRIMARY KEY,
+    smer_id    BIGINT REFERENCES smers ON DELETE CASCADE NOT NULL,
+    revision   INTEGER                                    NOT NULL,
+
+    situation  TEXT                                       NOT NULL,
+    thoughts   TEXT[]                                     NOT NULL,
+    emotions   TEXT[]                                     NOT NULL,
+    reactions  TEXT[]                                     NOT NULL,
+
+    created_at timestamptz                                NOT NULL DEFAULT NOW(),
+
+    UNIQUE (smer_id, revision)
+);
+
+-- Existing entries start their history with the current state.
+INSERT INTO smer_revisions (smer_id, revision, situation, thoughts, emotions, reactions, created_at)
+SELECT id, 1, situation, thoughts, emotions, reactions, updated_at
+FROM smers;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE smer_revisions;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Trashed smers keep deleted_at until the purger removes them.
+ALTER TABLE smers ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX smers_deleted_at_idx ON smers (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX smers_deleted_at_idx;
+ALTER TABLE smers DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- User's own categories for smers (work, family, health...).
+CREATE TABLE tags
+(
+    id         BIGSERIAL                                  NOT NULL PRIMARY KEY,
+    user_id    BIGINT REFERENCES users ON DELETE CASCADE NOT NULL,
+    name       VARCHAR(50)                                NOT NULL,
+    color      VARCHAR(7)                                 NOT NULL,
+
+    created_at timestamptz                                NOT NULL DEFAULT NOW(),
+    updated_at timestamptz                                NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX tags_user_name_idx ON tags (user_id, lower(name));
+
+CREATE TRIGGER set_tags_timestamp
+    BEFORE UPDATE
+    ON tags
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+CREATE TABLE smer_tags
+(
+    smer_id BIGINT REFERENCES smers ON DELETE CASCADE NOT NULL,
+    tag_id  BIGINT REFERENCES tags ON DELETE CASCADE  NOT NULL,
+
+    PRIMARY KEY (smer_id, tag_id)
+);
+
+CREATE INDEX smer_tags_tag_idx ON smer_tags (tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE smer_tags;
+DROP TABLE tags;
+-- +goose StatementEnd
@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Shared suggestions offered next to the user's own words.
+-- family groups the words of the emotion wheel by the basic emotion.
+CREATE TABLE vocabulary_defaults
+(
+    id     BIGSERIAL   NOT NULL PRIMARY KEY,
+    kind   VARCHAR(20) NOT NULL,
+    name   TEXT        NOT NULL,
+    family TEXT,
+
+    UNIQUE (kind, name)
+);
+
+INSERT INTO vocabulary_defaults (kind, family, name)
+VALUES ('emotions', 'радость', 'радость'),
+       ('emotions', 'радость', 'счастье'),
+       ('emotions', 'радость', 'восторг'),
+       ('emotions', 'радость', 'удовлетворение'),
+       ('emotions', 'радость', 'гордость'),
+       ('emotions', 'радость', 'облегчение'),
+       ('emotions', 'радость', 'надежда'),
+       ('emotions', 'радость', 'спокойствие'),
+       ('emotions', 'грусть', 'грусть'),
+       ('emotions', 'грусть', 'печаль'),
+       ('emotions', 'грусть', 'тоска'),
+       ('emotions', 'грусть', 'одиночество'),
+       ('emotions', 'грусть', 'разочарование'),
+       ('emotions', 'грусть', 'отчаяние'),
+       ('emotions', 'грусть', 'беспомощность'),
+       ('emotions', 'грусть', 'вина'),
+       ('emotions', 'гнев', 'гнев'),
+       ('emotions', 'гнев', 'злость'),
+       ('emotions', 'гнев', 'раздражение'),
+       ('emotions', 'гнев', 'ярость'),
+       ('emotions', 'гнев', 'обида'),
+       ('emotions', 'гнев', 'возмущение'),
+       ('emotions', 'гнев', 'зависть'),
+       ('emotions', 'страх', 'страх'),
+       ('emotions', 'страх', 'тревога'),
+       ('emotions', 'страх', 'беспокойство'),
+       ('emotions', 'страх', 'паника'),
+       ('emotions', 'страх', 'ужас'),
+       ('emotions', 'страх', 'неуверенность'),
+       ('emotions', 'страх', 'растерянность'),
+       ('emotions', 'стыд', 'стыд'),
+       ('emotions', 'стыд', 'смущение'),
+       ('emotions', 'стыд', 'неловкость'),
+       ('emotions', 'стыд', 'унижение'),
+       ('emotions', 'отвращение', 'отвращение'),
+       ('emotions', 'отвращение', 'презрение'),
+       ('emotions', 'отвращение', 'неприязнь'),
+       ('emotions', 'удивление', 'удивление'),
+       ('emotions', 'удивление', 'изумление'),
+       ('emotions', 'удивление', 'замешательство'),
+       ('emotions', 'интерес', 'интерес'),
+       ('emotions', 'интерес', 'любопытство'),
+       ('emotions', 'интерес', 'воодушевление');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE vocabulary_defaults;
+-- +goose StatementEnd
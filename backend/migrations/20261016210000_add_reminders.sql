@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Journaling reminders. weekdays are 0 (Sunday) to 6, local_time is the
+-- time of day in timezone. next_run_at is the next send time in UTC, the
+-- scheduler moves it forward when it claims a reminder.
+CREATE TABLE reminders
+(
+    id           BIGSERIAL                                 NOT NULL PRIMARY KEY,
+    user_id      BIGINT REFERENCES users ON DELETE CASCADE NOT NULL,
+    weekdays     SMALLINT[]                                NOT NULL,
+    local_time   TIME                                      NOT NULL,
+    timezone     TEXT                                      NOT NULL,
+    enabled      BOOLEAN                                   NOT NULL DEFAULT TRUE,
+    next_run_at  TIMESTAMPTZ                               NOT NULL,
+    last_sent_at TIMESTAMPTZ,
+
+    created_at   timestamptz                               NOT NULL DEFAULT NOW(),
+    updated_at   timestamptz                               NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX reminders_next_run_at_idx ON reminders (next_run_at) WHERE enabled;
+
+CREATE TRIGGER set_reminders_timestamp
+    BEFORE UPDATE
+    ON reminders
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE reminders;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Background jobs. Workers claim pending jobs with FOR UPDATE SKIP LOCKED,
+-- a finished job is deleted, a failed one is retried with backoff until
+-- it runs out of attempts and becomes dead.
+CREATE TABLE jobs
+(
+    id           BIGSERIAL   NOT NULL PRIMARY KEY,
+    kind         TEXT        NOT NULL,
+    payload      JSONB       NOT NULL,
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts     INT         NOT NULL DEFAULT 0,
+    max_attempts INT         NOT NULL,
+    run_at       timestamptz NOT NULL DEFAULT NOW(),
+    locked_at    timestamptz,
+    last_error   TEXT,
+
+    created_at   timestamptz NOT NULL DEFAULT NOW(),
+    updated_at   timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX jobs_run_at_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX jobs_locked_at_idx ON jobs (locked_at) WHERE status = 'running';
+
+CREATE TRIGGER set_jobs_timestamp
+    BEFORE UPDATE
+    ON jobs
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE jobs;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Messages written in the same transaction as the change they announce.
+-- The dispatcher hands them over and deletes them, a failed one stays
+-- with its error and is retried on the next round.
+CREATE TABLE outbox
+(
+    id         BIGSERIAL   NOT NULL PRIMARY KEY,
+    topic      TEXT        NOT NULL,
+    payload    JSONB       NOT NULL,
+    attempts   INT         NOT NULL DEFAULT 0,
+    last_error TEXT,
+
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER set_outbox_timestamp
+    BEFORE UPDATE
+    ON outbox
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE outbox;
+-- +goose StatementEnd
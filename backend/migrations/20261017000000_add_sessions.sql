@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A session is a login on one device. Its refresh tokens form a chain:
+-- every refresh uses up the token and issues the next one, presenting
+-- a used token again revokes the whole session.
+CREATE TABLE sessions
+(
+    id           BIGSERIAL                                 NOT NULL PRIMARY KEY,
+    user_id      BIGINT REFERENCES users ON DELETE CASCADE NOT NULL,
+    user_agent   TEXT                                      NOT NULL DEFAULT '',
+    ip           TEXT                                      NOT NULL DEFAULT '',
+    expires_at   timestamptz                               NOT NULL,
+    revoked_at   timestamptz,
+
+    created_at   timestamptz                               NOT NULL DEFAULT NOW(),
+    last_used_at timestamptz                               NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- Only SHA-256 hashes of the tokens are stored.
+CREATE TABLE refresh_tokens
+(
+    id         BIGSERIAL                                    NOT NULL PRIMARY KEY,
+    session_id BIGINT REFERENCES sessions ON DELETE CASCADE NOT NULL,
+    token_hash TEXT                                         NOT NULL UNIQUE,
+    expires_at timestamptz                                  NOT NULL,
+    used_at    timestamptz,
+
+    created_at timestamptz                                  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+
+-- Refresh JWTs are no longer accepted.
+DELETE FROM tokens WHERE token_type = 'AUTH';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE refresh_tokens;
+DROP TABLE sessions;
+-- +goose StatementEnd
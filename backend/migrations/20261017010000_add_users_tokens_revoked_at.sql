@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Access tokens issued before this moment are rejected ("log out everywhere").
+ALTER TABLE users
+    ADD COLUMN tokens_revoked_at timestamptz;
+
+CREATE INDEX sessions_revoked_at_idx ON sessions (user_id, revoked_at) WHERE revoked_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX sessions_revoked_at_idx;
+
+ALTER TABLE users
+    DROP COLUMN tokens_revoked_at;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- TOTP secret of the user. It is pending until the first code is verified,
+-- last_step keeps an accepted code from being used again.
+CREATE TABLE user_totp
+(
+    user_id    BIGINT REFERENCES users ON DELETE CASCADE NOT NULL PRIMARY KEY,
+    secret     TEXT                                      NOT NULL,
+    enabled_at timestamptz,
+    last_step  BIGINT,
+
+    created_at timestamptz                               NOT NULL DEFAULT NOW(),
+    updated_at timestamptz                               NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER set_user_totp_timestamp
+    BEFORE UPDATE
+    ON user_totp
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- Single use codes for a lost authenticator, only SHA-256 hashes are stored.
+CREATE TABLE recovery_codes
+(
+    id         BIGSERIAL                                 NOT NULL PRIMARY KEY,
+    user_id    BIGINT REFERENCES users ON DELETE CASCADE NOT NULL,
+    code_hash  TEXT                                      NOT NULL,
+    used_at    timestamptz,
+
+    created_at timestamptz                               NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Token buckets and lockouts of the rate limiter when it is shared by
+-- several replicas. Rows past expires_at are no different from missing ones.
+CREATE TABLE rate_limits
+(
+    key          TEXT             NOT NULL PRIMARY KEY,
+    tokens       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    updated_at   timestamptz,
+    failures     INT              NOT NULL DEFAULT 0,
+    failed_at    timestamptz,
+    locked_until timestamptz,
+    expires_at   timestamptz      NOT NULL
+);
+
+CREATE INDEX rate_limits_expires_at_idx ON rate_limits (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE rate_limits;
+-- +goose StatementEnd
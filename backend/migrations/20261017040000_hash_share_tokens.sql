@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Invitation tokens are stored as SHA-256 hashes, the pending ones keep working.
+ALTER TABLE shares
+    RENAME COLUMN token TO token_hash;
+
+UPDATE shares
+SET token_hash = encode(sha256(token_hash::bytea), 'hex');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The tokens can not be restored, pending invitations have to be sent again.
+UPDATE shares
+SET revoked_at = NOW()
+WHERE accepted_at IS NULL
+  AND revoked_at IS NULL;
+
+ALTER TABLE shares
+    RENAME COLUMN token_hash TO token;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A message that keeps failing is moved to the dead letters instead of
+-- being retried forever, it is kept for inspection.
+ALTER TABLE outbox
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX outbox_pending_idx;
+
+ALTER TABLE outbox
+    DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Download tokens are stored as SHA-256 hashes and are only set once the
+-- archive is built, the links already sent keep working.
+ALTER TABLE account_exports
+    RENAME COLUMN token TO token_hash;
+
+ALTER TABLE account_exports
+    ALTER COLUMN token_hash DROP NOT NULL;
+
+UPDATE account_exports
+SET token_hash = CASE WHEN status = 'ready' THEN encode(sha256(token_hash::bytea), 'hex') END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The tokens can not be restored, archives have to be requested again.
+-- Built ones are expired, so the worker still removes their files.
+DELETE
+FROM account_exports
+WHERE status <> 'ready';
+
+UPDATE account_exports
+SET expires_at = NOW(),
+    token_hash = md5(random()::text) || id;
+
+ALTER TABLE account_exports
+    ALTER COLUMN token_hash SET NOT NULL;
+
+ALTER TABLE account_exports
+    RENAME COLUMN token_hash TO token;
+-- +goose StatementEnd
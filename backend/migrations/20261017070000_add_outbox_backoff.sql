@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A failed message waits before its next attempt, the delay grows with the attempts.
+ALTER TABLE outbox
+    ADD COLUMN next_attempt_at timestamptz NOT NULL DEFAULT NOW();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE outbox
+    DROP COLUMN next_attempt_at;
+-- +goose StatementEnd